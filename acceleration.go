@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// engineAccelerationSignals carry the rate of change of engine speed as a
+// signed 16-bit big-endian value, so deceleration reads as negative.
+var engineAccelerationSignals = []bitSignal{
+	{Name: "RPMRate", StartBit: 7, Length: 16, BigEndian: true, Signed: true, Factor: 1, Unit: "rpm/s", Comment: "Engine speed derivative over the last model tick"},
+}
+
+// decodeEngineAcceleration prints the signed rate with an explicit sign.
+func decodeEngineAcceleration(data []byte) string {
+	sig := engineAccelerationSignals[0]
+	return fmt.Sprintf("Engine Acceleration: %+.0f %s", sig.physical(data), sig.Unit)
+}
+
+// encodeEngineAcceleration sends the model's engine speed derivative,
+// saturated at the limits of the signed field.
+func encodeEngineAcceleration(v vehicleModel) [8]byte {
+	var data [8]byte
+	sig := engineAccelerationSignals[0]
+	limit := math.Pow(2, float64(sig.Length-1))
+	rate := math.Min(math.Max(v.rpmRate, -limit), limit-1)
+	if raw, err := sig.rawValue(rate); err == nil {
+		sig.insert(&data, raw)
+	}
+	return data
+}
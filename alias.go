@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseAliases parses an -alias value of friendly names for imported
+// messages and signals, as "Eng_Spd_RPM->EngineRPM,..." (Old=New is also
+// accepted).
+func parseAliases(spec string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		old, name, ok := strings.Cut(item, "->")
+		if !ok {
+			old, name, ok = strings.Cut(item, "=")
+		}
+		old, name = strings.TrimSpace(old), strings.TrimSpace(name)
+		if !ok || old == "" || name == "" {
+			return nil, fmt.Errorf("invalid alias %q, expected Old->New", item)
+		}
+		if prev, dup := aliases[old]; dup && prev != name {
+			return nil, fmt.Errorf("%s aliased to both %s and %s", old, prev, name)
+		}
+		aliases[old] = name
+	}
+	return aliases, nil
+}
+
+// applyAliases renames messages and their signals in place, so every later
+// lookup and output uses the friendly names. It returns the aliases that
+// matched nothing, which are most likely typos.
+func applyAliases(messages []CANMessage, aliases map[string]string) []string {
+	used := make(map[string]bool)
+	for i := range messages {
+		msg := &messages[i]
+		if name, ok := aliases[msg.Name]; ok {
+			used[msg.Name] = true
+			msg.Name = name
+		}
+		for j := range msg.Signals {
+			sig := &msg.Signals[j]
+			if name, ok := aliases[sig.Name]; ok {
+				used[sig.Name] = true
+				sig.Name = name
+			}
+		}
+	}
+
+	var unused []string
+	for old := range aliases {
+		if !used[old] {
+			unused = append(unused, old)
+		}
+	}
+	return unused
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// startAPI serves the HTTP API on addr in the background.
+func startAPI(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", handleHealthz)
+	mux.HandleFunc("GET /version", handleVersion)
+	mux.HandleFunc("GET /identity", handleIdentity)
+	mux.HandleFunc("GET /messages", handleMessages)
+	mux.HandleFunc("GET /stats", handleStats)
+	mux.HandleFunc("GET /state", handleState)
+	mux.HandleFunc("GET /stream", handleStream)
+	mux.HandleFunc("POST /sensor/{name}", handleSetOverride)
+	mux.HandleFunc("DELETE /sensor/{name}", handleClearOverride)
+	mux.HandleFunc("POST /light/{lamp}", handleSetLamp)
+	mux.HandleFunc("POST /status/{flag}", handleSetStatusFlag)
+	mux.HandleFunc("POST /frames/dump", handleDumpFrames)
+	mux.HandleFunc("POST /reset", handleReset)
+	mux.HandleFunc("GET /dtc", handleListDTCs)
+	mux.HandleFunc("DELETE /dtc", handleClearDTCs)
+	mux.HandleFunc("POST /dtc", handleSetDTC)
+	mux.HandleFunc("POST /ambient", handleSetAmbient)
+	mux.HandleFunc("POST /inject/{name}", handleInject)
+
+	go func() {
+		log.Printf("HTTP API listening on %s", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("HTTP API stopped: %v", err)
+		}
+	}()
+}
+
+// writeJSON encodes v as the JSON response body.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write HTTP response: %v", err)
+	}
+}
+
+// audit logs a state change made through the API together with the
+// caller's address, as a trail of who changed what during shared testing.
+func audit(r *http.Request, format string, args ...any) {
+	log.Printf("API audit: %s %s", r.RemoteAddr, fmt.Sprintf(format, args...))
+}
+
+// healthResponse is the body of GET /healthz.
+type healthResponse struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// handleHealthz reports whether the receive loop is connected and alive,
+// with 503 when it is not. It does not depend on the engine state.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if err := rxHealth.check(time.Now()); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, healthResponse{Status: "unhealthy", Reason: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// version identifies the simulator build, set at link time with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
+// apiVersion is bumped whenever an endpoint changes incompatibly.
+const apiVersion = 1
+
+// versionResponse is the body of GET /version.
+type versionResponse struct {
+	Version    string          `json:"version"`
+	APIVersion int             `json:"api_version"`
+	Messages   []string        `json:"messages"`
+	Features   map[string]bool `json:"features"`
+}
+
+// handleVersion describes the simulator and what it supports, so clients
+// can adapt to the server they are talking to.
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	resp := versionResponse{
+		Version:    version,
+		APIVersion: apiVersion,
+		Features: map[string]bool{
+			"obd":   true,
+			"uds":   true,
+			"j1939": j1939Enabled,
+			"fd":    false,
+		},
+	}
+	for _, id := range messageIDs() {
+		resp.Messages = append(resp.Messages, formatID(id)+" "+CAN_DBC[id].Name)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleIdentity returns the simulated vehicle's identity.
+func handleIdentity(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, identity)
+}
+
+// messageDefinition describes a message in GET /messages.
+type messageDefinition struct {
+	ID        string             `json:"id"`
+	Name      string             `json:"name"`
+	DataLen   uint8              `json:"data_len"`
+	Simulated bool               `json:"simulated"`
+	Unit      string             `json:"unit,omitempty"`
+	Comment   string             `json:"comment,omitempty"`
+	Signals   []signalDefinition `json:"signals,omitempty"`
+}
+
+// signalDefinition describes a signal of a messageDefinition.
+type signalDefinition struct {
+	Name      string  `json:"name"`
+	StartBit  uint8   `json:"start_bit"`
+	Length    uint8   `json:"length"`
+	BigEndian bool    `json:"big_endian"`
+	Signed    bool    `json:"signed"`
+	Factor    float64 `json:"factor,omitempty"`
+	Offset    float64 `json:"offset,omitempty"`
+	Unit      string  `json:"unit,omitempty"`
+	Comment   string  `json:"comment,omitempty"`
+}
+
+// handleMessages returns the active message definitions in ID order.
+func handleMessages(w http.ResponseWriter, r *http.Request) {
+	defs := []messageDefinition{}
+	for _, id := range messageIDs() {
+		msg := CAN_DBC[id]
+		def := messageDefinition{
+			ID:        formatID(msg.ID),
+			Name:      msg.Name,
+			DataLen:   msg.DataLen,
+			Simulated: msg.Encode != nil || msg.Value != nil,
+			Unit:      msg.Unit,
+			Comment:   msg.Comment,
+		}
+		for _, sig := range msg.Signals {
+			def.Signals = append(def.Signals, signalDefinition(sig))
+		}
+		defs = append(defs, def)
+	}
+	writeJSON(w, http.StatusOK, defs)
+}
+
+// statsResponse is the body of GET /stats.
+type statsResponse struct {
+	UptimeSeconds  float64            `json:"uptime_seconds"`
+	Messages       map[string]idStats `json:"messages"`
+	DiagLatency    *latencySummary    `json:"diag_latency,omitempty"`
+	BusQuiet       bool               `json:"bus_quiet"`
+	RxOverflows    uint64             `json:"rx_overflows"`
+	SinkDrops      uint64             `json:"sink_drops"`
+	TopReceived    []messageRate      `json:"top_received"`
+	TopTransmitted []messageRate      `json:"top_transmitted"`
+}
+
+// handleStats returns the live per-ID traffic counters.
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	snap := stats.snapshot()
+	uptime := stats.uptime()
+	resp := statsResponse{
+		UptimeSeconds:  uptime.Seconds(),
+		Messages:       make(map[string]idStats, len(snap)),
+		DiagLatency:    stats.diagLatencySummary(),
+		BusQuiet:       quietWatchdog.isQuiet(),
+		RxOverflows:    stats.overflows(),
+		SinkDrops:      stats.droppedBySinks(),
+		TopReceived:    busiest(snap, uptime, receivedFrames),
+		TopTransmitted: busiest(snap, uptime, transmittedFrames),
+	}
+	for id, e := range snap {
+		resp.Messages[formatID(id)] = e
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleDumpFrames writes the received frame history to the dump file.
+func handleDumpFrames(w http.ResponseWriter, r *http.Request) {
+	if recentFrames == nil {
+		http.Error(w, "frame history disabled (-ring-size 0)", http.StatusConflict)
+		return
+	}
+	n, err := recentFrames.dump(frameDumpPath, "vcan0")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Dumped last %d frames to %s", n, frameDumpPath)
+	writeJSON(w, http.StatusOK, map[string]any{"path": frameDumpPath, "frames": n})
+}
+
+// handleSetAmbient changes the ambient temperature model input. Unlike
+// POST /sensor/AmbientTemp, which only forces the transmitted reading, this
+// feeds through to every sub-model that depends on it.
+func handleSetAmbient(w http.ResponseWriter, r *http.Request) {
+	var req overrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Value == nil {
+		http.Error(w, `expected body {"value": <number>}`, http.StatusBadRequest)
+		return
+	}
+	temp := int(math.Round(*req.Value))
+	setAmbient(temp)
+	audit(r, "set ambient temperature = %d °C", temp)
+	writeJSON(w, http.StatusOK, map[string]any{"ambient": temp})
+}
+
+// injectRequest is the body of POST /inject/{name}.
+type injectRequest struct {
+	Signals map[string]int `json:"signals"`
+}
+
+// handleInject transmits a frame encoded from named signal values.
+func handleInject(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	var req injectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `expected body {"signals": {"<name>": <value>, ...}}`, http.StatusBadRequest)
+		return
+	}
+	frame, err := injector.InjectMessage(r.Context(), name, req.Signals)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	audit(r, "injected %s %v as %s", name, req.Signals, frame.String())
+	writeJSON(w, http.StatusOK, map[string]any{"name": name, "frame": frame.String()})
+}
+
+// handleReset clears accumulated vehicle state, like a ResetState frame.
+func handleReset(w http.ResponseWriter, r *http.Request) {
+	resetVehicleState()
+	audit(r, "reset vehicle state")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// dtcEntry describes a stored DTC in GET /dtc.
+type dtcEntry struct {
+	Code   string    `json:"code"`
+	SetAt  time.Time `json:"set_at"`
+	Active bool      `json:"active"` // The fault is present now
+}
+
+// handleListDTCs returns the stored DTCs in code order.
+func handleListDTCs(w http.ResponseWriter, r *http.Request) {
+	simulationMux.Lock()
+	entries := dtcEntries()
+	simulationMux.Unlock()
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// dtcEntries lists the stored DTCs, latched faults first and injected
+// codes after, each in code order. The caller must hold simulationMux.
+func dtcEntries() []dtcEntry {
+	stored := dtcs.list()
+	injected := dtcs.listInjected()
+	entries := make([]dtcEntry, 0, len(stored)+len(injected))
+	for _, dtc := range stored {
+		entries = append(entries, dtcEntry{Code: faultName(dtc.Code), SetAt: dtc.SetAt, Active: slices.Contains(vehicle.activeFaults, dtc.Code)})
+	}
+	for _, dtc := range injected {
+		entries = append(entries, dtcEntry{Code: dtc.Code, SetAt: dtc.SetAt, Active: true})
+	}
+	return entries
+}
+
+// dtcRequest is the body of POST /dtc.
+type dtcRequest struct {
+	Code   string `json:"code"`
+	Active *bool  `json:"active"`
+}
+
+// handleSetDTC injects an SAE trouble code as present, or clears one that
+// was injected, without driving any sensor out of range.
+func handleSetDTC(w http.ResponseWriter, r *http.Request) {
+	var req dtcRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Active == nil {
+		http.Error(w, `expected body {"code": "<DTC>", "active": <bool>}`, http.StatusBadRequest)
+		return
+	}
+	code, err := parseDTC(req.Code)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	simulationMux.Lock()
+	if *req.Active {
+		dtcs.inject(code, time.Now())
+	} else if !dtcs.remove(code) {
+		simulationMux.Unlock()
+		http.Error(w, fmt.Sprintf("DTC %s is not injected", code), http.StatusNotFound)
+		return
+	}
+	simulationMux.Unlock()
+
+	audit(r, "set DTC %s active = %t", code, *req.Active)
+	writeJSON(w, http.StatusOK, map[string]any{"code": code, "active": *req.Active})
+}
+
+// handleClearDTCs clears the stored DTCs. Faults still present set their
+// codes again at once.
+func handleClearDTCs(w http.ResponseWriter, r *http.Request) {
+	clearDTCs()
+	audit(r, "cleared DTCs")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// overrideRequest is the body of POST /sensor/{name}.
+type overrideRequest struct {
+	Value *float64 `json:"value"`
+}
+
+// handleSetOverride forces a sensor's transmitted value until cleared.
+func handleSetOverride(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	var req overrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Value == nil {
+		http.Error(w, `expected body {"value": <number>}`, http.StatusBadRequest)
+		return
+	}
+	if err := setOverride(name, *req.Value); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	audit(r, "set sensor %s = %g", name, *req.Value)
+	writeJSON(w, http.StatusOK, map[string]any{"name": name, "value": *req.Value})
+}
+
+// handleClearOverride returns a sensor to the model.
+func handleClearOverride(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if _, ok := sensorFields[name]; !ok {
+		http.Error(w, fmt.Sprintf("unknown sensor %q", name), http.StatusNotFound)
+		return
+	}
+	clearOverride(name)
+	audit(r, "cleared sensor %s override", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lampRequest is the body of POST /light/{lamp} and POST /status/{flag}.
+type lampRequest struct {
+	On *bool `json:"on"`
+}
+
+// handleSetLamp switches a single front lamp on or off.
+func handleSetLamp(w http.ResponseWriter, r *http.Request) {
+	lamp := r.PathValue("lamp")
+
+	var req lampRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.On == nil {
+		http.Error(w, `expected body {"on": <bool>}`, http.StatusBadRequest)
+		return
+	}
+	if err := setLamp(lamp, *req.On); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	audit(r, "set lamp %s = %t", lamp, *req.On)
+	writeJSON(w, http.StatusOK, map[string]any{"lamp": lamp, "on": *req.On})
+}
+
+// handleSetStatusFlag sets or clears a single VehicleStatus flag.
+func handleSetStatusFlag(w http.ResponseWriter, r *http.Request) {
+	flag := r.PathValue("flag")
+
+	var req lampRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.On == nil {
+		http.Error(w, `expected body {"on": <bool>}`, http.StatusBadRequest)
+		return
+	}
+	if err := setStatusFlag(flag, *req.On); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	audit(r, "set status flag %s = %t", flag, *req.On)
+	writeJSON(w, http.StatusOK, map[string]any{"flag": flag, "on": *req.On})
+}
+
+// stateResponse is the body of GET /state. There is no odometer: the model
+// has no vehicle speed to accumulate distance from.
+type stateResponse struct {
+	EngineOn      bool               `json:"engine_on"`
+	Idle          bool               `json:"idle"`
+	ClosedLoop    bool               `json:"closed_loop"`
+	LimpHome      bool               `json:"limp_home"`
+	KeyPosition   string             `json:"key_position"`
+	FuelLevel     float64            `json:"fuel_level"`
+	FrontLights   map[string]bool    `json:"front_lights"`
+	StatusFlags   map[string]bool    `json:"status_flags"`
+	Sensors       map[string]float64 `json:"sensors"`
+	ActiveFaults  []string           `json:"active_faults"`
+	DTCs          []dtcEntry         `json:"dtcs"` // As in GET /dtc
+	UptimeSeconds float64            `json:"uptime_seconds"`
+}
+
+// handleState returns a coherent point-in-time snapshot of the vehicle
+// model, as transmitted (overrides applied).
+func handleState(w http.ResponseWriter, r *http.Request) {
+	simulationMux.Lock()
+	state := vehicle
+	state.applyOverrides()
+	running := engineOn
+	stored := dtcEntries()
+	simulationMux.Unlock()
+
+	resp := stateResponse{
+		EngineOn:      running,
+		Idle:          state.idle,
+		ClosedLoop:    state.closedLoop,
+		LimpHome:      state.limpHome,
+		KeyPosition:   state.ignition.String(),
+		FuelLevel:     float64(state.fuelTankLevel),
+		FrontLights:   state.lampStates(),
+		StatusFlags:   state.statusFlagStates(),
+		Sensors:       state.sensorValues(),
+		ActiveFaults:  []string{},
+		DTCs:          stored,
+		UptimeSeconds: stats.uptime().Seconds(),
+	}
+	for _, code := range state.activeFaults {
+		resp.ActiveFaults = append(resp.ActiveFaults, faultName(code))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// streamBuffer is how many signal changes a slow /stream client may fall
+// behind before it starts missing them.
+const streamBuffer = 256
+
+// signalChangeEvent is the JSON data of each GET /stream event.
+type signalChangeEvent struct {
+	Name string    `json:"name"`
+	Old  float64   `json:"old"`
+	New  float64   `json:"new"`
+	Time time.Time `json:"time"`
+}
+
+// handleStream pushes received signal changes as Server-Sent Events until
+// the client disconnects.
+func handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	changes, unsubscribe := SubscribeSignalChanges(streamBuffer)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case change := <-changes:
+			data, err := json.Marshal(signalChangeEvent(change))
+			if err != nil {
+				log.Printf("failed to encode signal change: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
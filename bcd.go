@@ -0,0 +1,56 @@
+package main
+
+import "fmt"
+
+// Binary-coded decimal packs two decimal digits per byte, high nibble
+// first, so 0x45 is 45 rather than 69.
+
+// decodeBCD reads the decimal number packed in data. It reports false if a
+// nibble is not a decimal digit.
+func decodeBCD(data []byte) (int, bool) {
+	value := 0
+	for _, b := range data {
+		hi, lo := int(b>>4), int(b&0x0F)
+		if hi > 9 || lo > 9 {
+			return 0, false
+		}
+		value = value*100 + hi*10 + lo
+	}
+	return value, true
+}
+
+// encodeBCD packs value into n bytes of BCD, keeping the low 2n digits.
+func encodeBCD(value, n int) []byte {
+	data := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		data[i] = byte((value/10%10)<<4 | value%10)
+		value /= 100
+	}
+	return data
+}
+
+// ecuSoftwareVersion is the major, minor and patch number the simulated ECU
+// reports in its SoftwareVersion message.
+var ecuSoftwareVersion = [3]int{2, 4, 15}
+
+// decodeSoftwareVersion reads the major, minor and patch numbers, one BCD
+// byte each.
+func decodeSoftwareVersion(data []byte) string {
+	var parts [3]int
+	for i := range parts {
+		n, ok := decodeBCD(data[i : i+1])
+		if !ok {
+			return fmt.Sprintf("Software Version: invalid BCD % x", data[:3])
+		}
+		parts[i] = n
+	}
+	return fmt.Sprintf("Software Version: %d.%d.%d", parts[0], parts[1], parts[2])
+}
+
+func encodeSoftwareVersion(v vehicleModel) [8]byte {
+	var data [8]byte
+	for i, n := range ecuSoftwareVersion {
+		copy(data[i:], encodeBCD(n, 1))
+	}
+	return data
+}
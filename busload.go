@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// frameBits returns the worst-case length on the wire of a classic CAN
+// data frame with n data bytes, including stuff bits and the 3-bit
+// inter-frame space. Stuffing applies from SOF to the end of the CRC, at
+// most one stuff bit per four bits after the first.
+func frameBits(n uint8, extended bool) int {
+	stuffed := 34 + 8*int(n) // SOF, 11-bit ID, RTR, IDE, r0, DLC, data, CRC
+	if extended {
+		stuffed = 54 + 8*int(n) // Adds SRR, the 18-bit ID extension and r1
+	}
+	const trailer = 1 + 2 + 7 + 3 // CRC delimiter, ACK slot and delimiter, EOF, IFS
+	return stuffed + (stuffed-1)/4 + trailer
+}
+
+// busLoadEntry is one cyclic frame's share of the bus.
+type busLoadEntry struct {
+	name     string
+	id       uint32
+	dlc      uint8
+	extended bool
+	interval time.Duration
+}
+
+// load returns the fraction of the bus the frame occupies at bitrate.
+func (e busLoadEntry) load(bitrate int) float64 {
+	return float64(frameBits(e.dlc, e.extended)) / float64(bitrate) / e.interval.Seconds()
+}
+
+// runBusLoad implements "vecu busload": compute the theoretical bus
+// utilization of the message set the simulator would transmit, from each
+// message's DLC and interval, without opening the bus.
+func runBusLoad(args []string) error {
+	fs := flag.NewFlagSet("busload", flag.ExitOnError)
+	bitrate := fs.Int("bitrate", 500000, "bus bitrate in bit/s")
+	dbcPath := fs.String("dbc", "", "also include messages from this DBC file")
+	aliasSpec := fs.String("alias", "", "rename imported DBC messages and signals as Old->New,...")
+	intervals := fs.String("interval", "", "transmit intervals as Name=duration,... (e.g. EngineRPM=1ms)")
+	fs.BoolVar(&padFrames, "pad", false, "assume frames padded to 8 bytes")
+	fs.BoolVar(&j1939Enabled, "j1939", false, "include the J1939 PGNs")
+	fs.Parse(args)
+
+	if *bitrate <= 0 {
+		return fmt.Errorf("busload: invalid -bitrate %d", *bitrate)
+	}
+	var aliases map[string]string
+	if *aliasSpec != "" {
+		var err error
+		if aliases, err = parseAliases(*aliasSpec); err != nil {
+			return err
+		}
+	}
+	if err := loadMessages(*dbcPath, aliases); err != nil {
+		return err
+	}
+	if *intervals != "" {
+		if err := applyIntervals(*intervals); err != nil {
+			return err
+		}
+	}
+
+	var entries []busLoadEntry
+	for _, msg := range append(simulatedMessages(false), simulatedMessages(true)...) {
+		frame := msg.frame([8]byte{})
+		entries = append(entries, busLoadEntry{name: msg.Name, id: msg.ID, dlc: frame.Length, extended: msg.ID > 0x7FF, interval: msg.interval()})
+	}
+	if j1939Enabled {
+		for _, msg := range j1939Messages {
+			frame := j1939Frame(msg, vehicleModel{})
+			entries = append(entries, busLoadEntry{name: msg.Name, id: frame.ID, dlc: frame.Length, extended: true, interval: defaultInterval})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].id < entries[j].id })
+
+	var total float64
+	fmt.Printf("%-10s %-22s %3s %10s %5s %8s\n", "ID", "Message", "DLC", "Interval", "Bits", "Load")
+	for _, e := range entries {
+		load := e.load(*bitrate)
+		total += load
+		fmt.Printf("%-10s %-22s %3d %10s %5d %7.3f%%\n", formatID(e.id), e.name, e.dlc, e.interval, frameBits(e.dlc, e.extended), load*100)
+	}
+	fmt.Printf("Total bus load at %d bit/s: %.2f%%\n", *bitrate, total*100)
+	if total > 1 {
+		log.Printf("Warning: the message set needs %.0f%% of the bus and cannot be transmitted on time", total*100)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFrameBits(t *testing.T) {
+	tests := []struct {
+		n        uint8
+		extended bool
+		want     int
+	}{
+		{n: 0, want: 55},
+		{n: 1, want: 65},
+		{n: 8, want: 135},
+		{n: 0, extended: true, want: 80},
+		{n: 8, extended: true, want: 160},
+	}
+	for _, tt := range tests {
+		if got := frameBits(tt.n, tt.extended); got != tt.want {
+			t.Errorf("frameBits(%d, %t) = %d, want %d", tt.n, tt.extended, got, tt.want)
+		}
+	}
+}
+
+func TestBusLoadEntryLoad(t *testing.T) {
+	e := busLoadEntry{name: "EngineRPM", id: 0x205, dlc: 8, interval: 10 * time.Millisecond}
+	// 135 bits a hundred times a second on a 500 kbit/s bus
+	if got, want := e.load(500000), 0.027; math.Abs(got-want) > 1e-12 {
+		t.Errorf("load() = %g, want %g", got, want)
+	}
+}
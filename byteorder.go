@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// byteOrder is the byte order of a message's multi-byte fields. The zero
+// value defers to the -byteorder default.
+type byteOrder uint8
+
+const (
+	byteOrderDefault byteOrder = iota
+	byteOrderBig
+	byteOrderLittle
+)
+
+// defaultByteOrder applies to messages without a ByteOrder of their own.
+// It is set once from -byteorder before the simulator starts.
+var defaultByteOrder = byteOrderBig
+
+// parseByteOrder parses "big" or "little".
+func parseByteOrder(order string) (byteOrder, error) {
+	switch order {
+	case "big":
+		return byteOrderBig, nil
+	case "little":
+		return byteOrderLittle, nil
+	}
+	return byteOrderDefault, fmt.Errorf("invalid byte order %q, expected big or little", order)
+}
+
+// littleEndian resolves the message byte order, its own if set, otherwise
+// the default.
+func (m CANMessage) littleEndian() bool {
+	if m.ByteOrder != byteOrderDefault {
+		return m.ByteOrder == byteOrderLittle
+	}
+	return defaultByteOrder == byteOrderLittle
+}
+
+// byteOrder returns the resolved byte order of the message.
+func (m CANMessage) byteOrder() binary.ByteOrder {
+	if m.littleEndian() {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// byteOrderOf returns the resolved byte order of a named message, for the
+// hand-written encoders and decoders, which are not passed their message.
+func byteOrderOf(name string) binary.ByteOrder {
+	msg, _ := MessageByName(name)
+	return msg.byteOrder()
+}
+
+// applyByteOrders sets the default byte order and per-message overrides
+// from a -byteorder value like "little,EngineRPM=big".
+func applyByteOrders(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		name, value, override := strings.Cut(strings.TrimSpace(item), "=")
+		if !override {
+			order, err := parseByteOrder(name)
+			if err != nil {
+				return err
+			}
+			defaultByteOrder = order
+			continue
+		}
+		msg, ok := MessageByName(name)
+		if !ok {
+			return fmt.Errorf("unknown message %q in byte orders", name)
+		}
+		order, err := parseByteOrder(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		msg.ByteOrder = order
+		updateMessage(msg)
+	}
+	return nil
+}
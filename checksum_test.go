@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyChecksums(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr string
+	}{
+		{spec: "EngineTempSensor=xor"},
+		{spec: "EngineRPM=none"},
+		{spec: "EngineTempSensor=md5", wantErr: `unknown checksum "md5"`},
+		{spec: "NoSuchMessage=xor", wantErr: `unknown message "NoSuchMessage"`},
+		{spec: "EngineTempSensor", wantErr: "expected Name=algorithm"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if err := loadMessages("", nil); err != nil {
+				t.Fatal(err)
+			}
+			err := applyChecksums(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("applyChecksums(%q) error = %v, want %q", tt.spec, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyChecksums(%q): %v", tt.spec, err)
+			}
+			name, alg, _ := strings.Cut(tt.spec, "=")
+			msg, _ := MessageByName(name)
+			if want := checksums[alg]; msg.Checksum != want {
+				t.Errorf("%s checksum = %v, want %v", name, msg.Checksum, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "time"
+
+// timeScale speeds up the vehicle model relative to the wall clock, set
+// with -timescale. Warm-up, cool-down, coast-down and engine hours all run
+// on the model clock, while frames keep their wall-clock cadence.
+var timeScale = 1.0
+
+// clockEpoch anchors the model clock to the wall clock.
+var clockEpoch = time.Now()
+
+// modelNow returns the current time on the model clock.
+func modelNow() time.Time {
+	return clockEpoch.Add(modelDuration(time.Since(clockEpoch)))
+}
+
+// modelDuration converts a wall-clock interval to model time.
+func modelDuration(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * timeScale)
+}
@@ -0,0 +1,68 @@
+package main
+
+import "go.einride.tech/can"
+
+// Messages with CounterBits set carry a rolling counter in the low
+// CounterBits of data byte CounterByte. It increments on every transmit of
+// the message and wraps at 2^CounterBits.
+
+// counterMask returns the mask of the counter bits.
+func (m CANMessage) counterMask() byte {
+	return byte(1<<m.CounterBits - 1)
+}
+
+// applyCounter writes the rolling counter value into the payload.
+func (m CANMessage) applyCounter(data *[8]byte, value uint8) {
+	if m.CounterBits == 0 {
+		return
+	}
+	mask := m.counterMask()
+	data[m.CounterByte] = data[m.CounterByte]&^mask | value&mask
+}
+
+// txCounters tracks the next counter value per message on the transmit
+// side. Each transmit loop owns its own counters.
+type txCounters map[uint32]uint8
+
+// next returns the counter value for the next frame of msg and advances it.
+func (c txCounters) next(msg CANMessage) uint8 {
+	value := c[msg.ID]
+	c[msg.ID] = (value + 1) & msg.counterMask()
+	return value
+}
+
+// nextByte returns a full 8-bit counter for payload templates, which place
+// ${counter} in a byte of their own, and advances it.
+func (c txCounters) nextByte(id uint32) uint8 {
+	value := c[id]
+	c[id] = value + 1
+	return value
+}
+
+// rxCounters tracks the expected next counter value per message on the
+// receive side. It is only used from the receive loop.
+type rxCounters map[uint32]uint8
+
+// check validates the counter in a received frame against the previous one
+// for the same ID, warning when frames were skipped or repeated.
+func (c rxCounters) check(msg CANMessage, frame can.Frame) {
+	if msg.CounterBits == 0 || int(msg.CounterByte) >= int(frame.Length) {
+		return
+	}
+	mask := msg.counterMask()
+	value := frame.Data[msg.CounterByte] & mask
+
+	expected, seen := c[frame.ID]
+	c[frame.ID] = (value + 1) & mask
+	if !seen || value == expected {
+		return
+	}
+
+	if value == (expected-1)&mask {
+		warnFrame(frame, "Frame ID 0x%x counter repeated value %d (duplicated frame)", frame.ID, value)
+		return
+	}
+	lost := (value - expected) & mask
+	stats.recordLost(frame.ID, uint64(lost))
+	warnFrame(frame, "Frame ID 0x%x counter skipped from %d to %d (%d frames lost)", frame.ID, expected, value, lost)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// loadDBC indexes messages by ID after checking that no two messages share
+// an ID or a name and that no message has overlapping signals. All
+// conflicts are reported together so a merged DBC can be fixed in one pass.
+func loadDBC(messages []CANMessage) (map[uint32]CANMessage, error) {
+	byID := make(map[uint32][]string)
+	byName := make(map[string][]uint32)
+	for _, msg := range messages {
+		byID[msg.ID] = append(byID[msg.ID], msg.Name)
+		byName[msg.Name] = append(byName[msg.Name], msg.ID)
+	}
+
+	var conflicts []string
+	for id, names := range byID {
+		if len(names) > 1 {
+			conflicts = append(conflicts, fmt.Sprintf("ID 0x%03x used by %s", id, strings.Join(names, ", ")))
+		}
+	}
+	for name, ids := range byName {
+		if len(ids) > 1 {
+			formatted := make([]string, len(ids))
+			for i, id := range ids {
+				formatted[i] = fmt.Sprintf("0x%03x", id)
+			}
+			conflicts = append(conflicts, fmt.Sprintf("name %s used by %s", name, strings.Join(formatted, ", ")))
+		}
+	}
+	for _, msg := range messages {
+		conflicts = append(conflicts, msg.layoutConflicts()...)
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, fmt.Errorf("invalid DBC: %s", strings.Join(conflicts, "; "))
+	}
+
+	dbc := make(map[uint32]CANMessage, len(messages))
+	for _, msg := range messages {
+		dbc[msg.ID] = msg
+	}
+	return dbc, nil
+}
+
+// layout returns every bit range the message occupies: its declared
+// signals plus the physical value, rolling counter and checksum.
+func (m CANMessage) layout() []bitSignal {
+	fields := append([]bitSignal(nil), m.Signals...)
+	if m.Value != nil {
+		fields = append(fields, bitSignal{Name: "value", StartBit: 0, Length: m.ValueLen * 8})
+	}
+	if m.CounterBits > 0 {
+		fields = append(fields, bitSignal{Name: "counter", StartBit: m.CounterByte * 8, Length: m.CounterBits})
+	}
+	if m.Checksum != nil && m.DataLen > 0 {
+		fields = append(fields, bitSignal{Name: "checksum", StartBit: (m.DataLen - 1) * 8, Length: 8})
+	}
+	return fields
+}
+
+// layoutConflicts describes every pair of overlapping fields in the
+// message, and any field that extends past the end of its payload.
+func (m CANMessage) layoutConflicts() []string {
+	var conflicts []string
+	fields := m.layout()
+	owners := make([]map[int]bool, len(fields))
+	for i, f := range fields {
+		owners[i] = make(map[int]bool, f.Length)
+		past := false
+		for _, bit := range f.bits() {
+			owners[i][bit] = true
+			past = past || bit >= int(m.DataLen)*8
+		}
+		if past {
+			conflicts = append(conflicts, fmt.Sprintf("%s signal %s extends past its %d-byte payload", m.Name, f.Name, m.DataLen))
+		}
+	}
+	for i, a := range fields {
+		for j := i + 1; j < len(fields); j++ {
+			var shared []string
+			for _, bit := range fields[j].bits() {
+				if owners[i][bit] {
+					shared = append(shared, strconv.Itoa(bit))
+				}
+			}
+			if len(shared) > 0 {
+				conflicts = append(conflicts, fmt.Sprintf("%s signals %s and %s overlap at bit %s", m.Name, a.Name, fields[j].Name, strings.Join(shared, ", ")))
+			}
+		}
+	}
+	return conflicts
+}
+
+// dbcByName indexes CAN_DBC by message name. It is kept in sync by useDBC.
+var dbcByName map[string]CANMessage
+
+// useDBC installs a loaded DBC as the active message set.
+func useDBC(dbc map[uint32]CANMessage) {
+	byName := make(map[string]CANMessage, len(dbc))
+	for _, msg := range dbc {
+		byName[msg.Name] = msg
+	}
+	CAN_DBC = dbc
+	dbcByName = byName
+}
+
+// updateMessage replaces an active message definition after it has been
+// reconfigured.
+func updateMessage(msg CANMessage) {
+	CAN_DBC[msg.ID] = msg
+	dbcByName[msg.Name] = msg
+}
+
+// messageIDs returns the active message IDs in ascending order.
+func messageIDs() []uint32 {
+	ids := make([]uint32, 0, len(CAN_DBC))
+	for id := range CAN_DBC {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// MessageByID returns the active message definition for a CAN ID.
+func MessageByID(id uint32) (CANMessage, bool) {
+	msg, ok := CAN_DBC[id]
+	return msg, ok
+}
+
+// MessageByName returns the active message definition with the given name.
+func MessageByName(name string) (CANMessage, bool) {
+	msg, ok := dbcByName[name]
+	return msg, ok
+}
+
+// SignalValue returns the current physical value of a named signal as it
+// is being transmitted, overrides included.
+func SignalValue(name string) (float64, bool) {
+	simulationMux.Lock()
+	state := vehicle
+	state.applyOverrides()
+	simulationMux.Unlock()
+
+	value, ok := state.sensorValues()[name]
+	return value, ok
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLayoutConflictsChecksumInLastByte(t *testing.T) {
+	tests := []struct {
+		name    string
+		signals []bitSignal
+		want    []string
+	}{
+		{
+			name:    "clear of the checksum",
+			signals: []bitSignal{{Name: "Level", StartBit: 0, Length: 16}},
+		},
+		{
+			name:    "over the checksum",
+			signals: []bitSignal{{Name: "Level", StartBit: 16, Length: 16}},
+			want:    []string{"Tank signals Level and checksum overlap at bit 24, 25, 26, 27, 28, 29, 30, 31"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := CANMessage{ID: 0x300, Name: "Tank", DataLen: 4, Checksum: checksumXOR, Signals: tt.signals}
+			if got := msg.layoutConflicts(); !slices.Equal(got, tt.want) {
+				t.Errorf("layoutConflicts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
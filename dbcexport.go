@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// dbcNode is the transmitting node of every exported message.
+const dbcNode = "VECU"
+
+// exportDBC writes the active message set to path as a Vector DBC file,
+// for -export-dbc. It is the inverse of importDBCFile: messages, signals,
+// units, comments and the transmit intervals of simulated messages, as the
+// GenMsgCycleTime attribute, round-trip. Decoders, encoders and templates
+// have no DBC form and are not exported.
+func exportDBC(path string) error {
+	var b strings.Builder
+	b.WriteString("VERSION \"\"\n\nNS_ :\n\nBS_:\n\nBU_: " + dbcNode + "\n")
+
+	var comments, attributes []string
+	for _, id := range messageIDs() {
+		msg := CAN_DBC[id]
+		dbcID := msg.ID
+		if dbcID > 0x7FF {
+			dbcID |= 0x80000000 // Extended frame flag
+		}
+		fmt.Fprintf(&b, "\nBO_ %d %s: %d %s\n", dbcID, msg.Name, msg.DataLen, dbcNode)
+		for _, sig := range msg.exportSignals() {
+			fmt.Fprintf(&b, " SG_ %s : %s\n", sig.Name, sig.dbcLayout())
+			if sig.Comment != "" {
+				comments = append(comments, fmt.Sprintf("CM_ SG_ %d %s %s;", dbcID, sig.Name, dbcString(sig.Comment)))
+			}
+		}
+		if msg.Comment != "" {
+			comments = append(comments, fmt.Sprintf("CM_ BO_ %d %s;", dbcID, dbcString(msg.Comment)))
+		}
+		if msg.Encode != nil || msg.Value != nil || msg.Template != nil {
+			attributes = append(attributes, fmt.Sprintf("BA_ %q BO_ %d %d;", cycleTimeAttribute, dbcID, msg.interval().Milliseconds()))
+		}
+	}
+
+	b.WriteString("\n")
+	for _, line := range comments {
+		b.WriteString(line + "\n")
+	}
+	fmt.Fprintf(&b, "BA_DEF_ BO_ %q INT 0 65535;\n", cycleTimeAttribute)
+	fmt.Fprintf(&b, "BA_DEF_DEF_ %q 0;\n", cycleTimeAttribute)
+	for _, line := range attributes {
+		b.WriteString(line + "\n")
+	}
+
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to export DBC: %w", err)
+	}
+	return nil
+}
+
+// exportSignals returns the signals describing the message payload: its
+// declared signals, plus the physical value, rolling counter and checksum
+// that built-in messages define outside Signals.
+func (m CANMessage) exportSignals() []bitSignal {
+	signals := append([]bitSignal(nil), m.Signals...)
+	if m.Value != nil {
+		// Sent from the first byte; big-endian, the MSB is bit 7
+		value := bitSignal{Name: m.Name, StartBit: 7, Length: m.ValueLen * 8, BigEndian: true, Factor: m.factor(), Offset: m.Offset, Unit: m.Unit}
+		if m.littleEndian() {
+			value.StartBit, value.BigEndian = 0, false
+		}
+		signals = append(signals, value)
+	}
+	if m.CounterBits > 0 {
+		signals = append(signals, bitSignal{Name: "Counter", StartBit: m.CounterByte * 8, Length: m.CounterBits})
+	}
+	if m.Checksum != nil {
+		signals = append(signals, bitSignal{Name: "Checksum", StartBit: (m.DataLen - 1) * 8, Length: 8})
+	}
+	return signals
+}
+
+// dbcLayout renders the SG_ definition after the signal name, e.g.
+// `7|16@0+ (1,0) [0|65535] "rpm" Vector__XXX`.
+func (s bitSignal) dbcLayout() string {
+	order := "1"
+	if s.BigEndian {
+		order = "0"
+	}
+	sign := "+"
+	if s.Signed {
+		sign = "-"
+	}
+	factor := s.Factor
+	if factor == 0 {
+		factor = 1
+	}
+
+	// The physical range of the raw field
+	lo, hi := 0.0, math.Pow(2, float64(s.Length))-1
+	if s.Signed {
+		lo, hi = -math.Pow(2, float64(s.Length-1)), math.Pow(2, float64(s.Length-1))-1
+	}
+	// Rounded to the signal resolution, so 255*0.02 is written as 5.1
+	decimals := max(dbcDecimals(factor), dbcDecimals(s.Offset))
+	lo, hi = dbcRound(lo*factor+s.Offset, decimals), dbcRound(hi*factor+s.Offset, decimals)
+	if factor < 0 {
+		lo, hi = hi, lo
+	}
+
+	return fmt.Sprintf("%d|%d@%s%s (%s,%s) [%s|%s] %s Vector__XXX", s.StartBit, s.Length, order, sign,
+		dbcNumber(factor), dbcNumber(s.Offset), dbcNumber(lo), dbcNumber(hi), dbcString(s.Unit))
+}
+
+func dbcNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// dbcDecimals returns how many decimal places v is written with.
+func dbcDecimals(v float64) int {
+	_, frac, _ := strings.Cut(strconv.FormatFloat(v, 'f', -1, 64), ".")
+	return len(frac)
+}
+
+// dbcRound rounds v to the given number of decimal places.
+func dbcRound(v float64, decimals int) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'f', decimals, 64), 64)
+	return rounded
+}
+
+// dbcString quotes text for a DBC file, which has no escape for quotes.
+func dbcString(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, "'") + `"`
+}
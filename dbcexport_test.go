@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestExportDBCRoundTrip(t *testing.T) {
+	if err := loadMessages("", nil); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "export.dbc")
+	if err := exportDBC(path); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := importDBCFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != len(CAN_DBC) {
+		t.Fatalf("imported %d messages, exported %d", len(imported), len(CAN_DBC))
+	}
+
+	for _, got := range imported {
+		want, ok := CAN_DBC[got.ID]
+		if !ok {
+			t.Errorf("imported ID 0x%x was not exported", got.ID)
+			continue
+		}
+		if got.Name != want.Name || got.DataLen != want.DataLen || got.Comment != want.Comment {
+			t.Errorf("0x%x imported as %s, %d bytes, comment %q, want %s, %d bytes, comment %q",
+				got.ID, got.Name, got.DataLen, got.Comment, want.Name, want.DataLen, want.Comment)
+		}
+		if simulated := want.Encode != nil || want.Value != nil || want.Template != nil; simulated && got.Interval != want.interval() {
+			t.Errorf("%s interval = %v, want %v", want.Name, got.Interval, want.interval())
+		}
+		// An unscaled signal is exported with a factor of 1
+		wantSignals := want.exportSignals()
+		for i := range wantSignals {
+			if wantSignals[i].Factor == 0 {
+				wantSignals[i].Factor = 1
+			}
+		}
+		if !slices.Equal(got.Signals, wantSignals) {
+			t.Errorf("%s signals round-trip as\n%+v\nwant\n%+v", want.Name, got.Signals, wantSignals)
+		}
+	}
+}
+
+func TestDBCLayout(t *testing.T) {
+	tests := []struct {
+		sig  bitSignal
+		want string
+	}{
+		{
+			sig:  bitSignal{StartBit: 7, Length: 16, BigEndian: true, Unit: "rpm"},
+			want: `7|16@0+ (1,0) [0|65535] "rpm" Vector__XXX`,
+		},
+		{
+			sig:  bitSignal{StartBit: 8, Length: 8, Factor: 0.02, Unit: "V"},
+			want: `8|8@1+ (0.02,0) [0|5.1] "V" Vector__XXX`,
+		},
+		{
+			sig:  bitSignal{StartBit: 0, Length: 8, Signed: true, Factor: 0.1, Offset: -40},
+			want: `0|8@1- (0.1,-40) [-52.8|-27.3] "" Vector__XXX`,
+		},
+		{
+			sig:  bitSignal{StartBit: 0, Length: 8, Factor: -0.5},
+			want: `0|8@1+ (-0.5,0) [-127.5|0] "" Vector__XXX`,
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.sig.dbcLayout(); got != tt.want {
+			t.Errorf("dbcLayout(%+v) = %s, want %s", tt.sig, got, tt.want)
+		}
+	}
+}
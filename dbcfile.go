@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"go.einride.tech/can/pkg/dbc"
+)
+
+// importDBCFile reads the messages of a Vector DBC file, with their signal
+// layouts, the message (CM_ BO_) and signal (CM_ SG_) comments and their
+// cycle times (the GenMsgCycleTime attribute or its default, in ms).
+// loadMessages gives them a signal decoder, and an encoder for those with a
+// cycle time, once their names are final.
+func importDBCFile(path string) ([]CANMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DBC: %w", err)
+	}
+	parser := dbc.NewParser(path, data)
+	if err := parser.Parse(); err != nil {
+		return nil, fmt.Errorf("failed to parse DBC: %v", err)
+	}
+
+	var messages []CANMessage
+	index := make(map[dbc.MessageID]int)
+	for _, def := range parser.Defs() {
+		def, ok := def.(*dbc.MessageDef)
+		if !ok || dbc.IsIndependentSignalsMessage(def) {
+			continue
+		}
+		if def.Size > 8 {
+			return nil, fmt.Errorf("%s: message %s is %d bytes, only classic CAN is supported", def.Pos, def.Name, def.Size)
+		}
+		msg := CANMessage{ID: def.MessageID.ToCAN(), Name: string(def.Name), DataLen: uint8(def.Size)}
+		for _, sig := range def.Signals {
+			if sig.IsMultiplexed {
+				log.Printf("DBC %s: skipping multiplexed signal %s.%s", path, def.Name, sig.Name)
+				continue
+			}
+			msg.Signals = append(msg.Signals, bitSignal{
+				Name:      string(sig.Name),
+				StartBit:  uint8(sig.StartBit),
+				Length:    uint8(sig.Size),
+				BigEndian: sig.IsBigEndian,
+				Signed:    sig.IsSigned,
+				Factor:    sig.Factor,
+				Offset:    sig.Offset,
+				Unit:      sig.Unit,
+			})
+		}
+		index[def.MessageID] = len(messages)
+		messages = append(messages, msg)
+	}
+
+	// Comments and attributes may only refer to messages defined above
+	var defaultCycle time.Duration
+	for _, def := range parser.Defs() {
+		switch def := def.(type) {
+		case *dbc.CommentDef:
+			i, found := index[def.MessageID]
+			switch {
+			case def.ObjectType == dbc.ObjectTypeMessage && found:
+				messages[i].Comment = def.Comment
+			case def.ObjectType == dbc.ObjectTypeSignal && found:
+				for j := range messages[i].Signals {
+					if messages[i].Signals[j].Name == string(def.SignalName) {
+						messages[i].Signals[j].Comment = def.Comment
+					}
+				}
+			}
+		case *dbc.AttributeDefaultValueDef:
+			if def.AttributeName == cycleTimeAttribute {
+				defaultCycle = attributeMillis(def.DefaultIntValue, def.DefaultFloatValue)
+			}
+		case *dbc.AttributeValueForObjectDef:
+			if i, found := index[def.MessageID]; found && def.ObjectType == dbc.ObjectTypeMessage && def.AttributeName == cycleTimeAttribute {
+				messages[i].Interval = attributeMillis(def.IntValue, def.FloatValue)
+			}
+		}
+	}
+	for i := range messages {
+		if messages[i].Interval == 0 {
+			messages[i].Interval = defaultCycle
+		}
+	}
+	return messages, nil
+}
+
+// cycleTimeAttribute is the message attribute holding the cycle time in ms.
+const cycleTimeAttribute = "GenMsgCycleTime"
+
+// attributeMillis converts a millisecond attribute value, which the parser
+// stores as an int or a float depending on how it is written.
+func attributeMillis(intValue int64, floatValue float64) time.Duration {
+	if intValue == 0 {
+		return time.Duration(floatValue * float64(time.Millisecond))
+	}
+	return time.Duration(intValue) * time.Millisecond
+}
+
+// loadMessages installs the built-in messages, merged with those of the
+// DBC file at path if one is given and any extra definitions. Aliases
+// rename the imported messages and signals. A DBC file that does not exist
+// is warned about and skipped, so a mistyped path still runs with the
+// built-in messages; one that exists but cannot be read or parsed is an
+// error.
+func loadMessages(path string, aliases map[string]string, extra ...CANMessage) error {
+	messages := append(slices.Clip(builtinMessages), extra...)
+	if path != "" {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			log.Printf("Warning: DBC file %s not found, using the built-in messages only", path)
+			path = ""
+		}
+	}
+	if path != "" {
+		imported, err := importDBCFile(path)
+		if err != nil {
+			return err
+		}
+		for _, old := range applyAliases(imported, aliases) {
+			log.Printf("Warning: alias for %s matches no message or signal in %s", old, path)
+		}
+		for i := range imported {
+			imported[i].Decode = decodeSignals(imported[i].Name, imported[i].Signals)
+			if imported[i].Interval > 0 {
+				imported[i].Encode = encodeModelSignals(imported[i].Signals)
+				imported[i].RequiresEngine = true
+			}
+		}
+		messages = append(messages, imported...)
+	}
+	dbc, err := loadDBC(messages)
+	if err != nil {
+		return err
+	}
+	useDBC(dbc)
+	return nil
+}
+
+// listMessages prints the active message definitions with their signals
+// and comments, for -list.
+func listMessages() {
+	for _, id := range messageIDs() {
+		msg := CAN_DBC[id]
+		fmt.Printf("%s  %-22s %d bytes", formatID(msg.ID), msg.Name, msg.DataLen)
+		if msg.Unit != "" {
+			fmt.Printf(" [%s]", msg.Unit)
+		}
+		if msg.Comment != "" {
+			fmt.Printf("  // %s", msg.Comment)
+		}
+		fmt.Println()
+		for _, sig := range msg.Signals {
+			fmt.Printf("       %-22s bit %d, %d bits", sig.Name, sig.StartBit, sig.Length)
+			if sig.Unit != "" {
+				fmt.Printf(" [%s]", sig.Unit)
+			}
+			if sig.Comment != "" {
+				fmt.Printf("  // %s", sig.Comment)
+			}
+			fmt.Println()
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.einride.tech/can"
+	"go.einride.tech/can/pkg/socketcan"
+)
+
+// requestResponse transmits req on iface and waits for the first frame with
+// ID respID. It gives up once timeout elapses or ctx is cancelled.
+//
+// A dedicated connection is opened for every request so the response is not
+// stolen from other readers of the bus, and closing it on return guarantees
+// the receive goroutine exits.
+func requestResponse(ctx context.Context, iface string, req can.Frame, respID uint32, timeout time.Duration) (can.Frame, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := socketcan.DialContext(ctx, "can", iface)
+	if err != nil {
+		return can.Frame{}, fmt.Errorf("failed to connect to %s: %w", iface, err)
+	}
+
+	responses := make(chan can.Frame, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		recv := socketcan.NewReceiver(conn)
+		for recv.Receive() {
+			if recv.HasErrorFrame() {
+				continue
+			}
+			if frame := recv.Frame(); frame.ID == respID {
+				responses <- frame
+				return
+			}
+		}
+	}()
+	defer func() {
+		conn.Close()
+		<-done
+	}()
+
+	if err := socketcan.NewTransmitter(conn).TransmitFrame(ctx, req); err != nil {
+		return can.Frame{}, fmt.Errorf("failed to transmit request 0x%x: %w", req.ID, err)
+	}
+
+	select {
+	case frame := <-responses:
+		return frame, nil
+	case <-ctx.Done():
+		return can.Frame{}, fmt.Errorf("no response 0x%x to request 0x%x: %w", respID, req.ID, ctx.Err())
+	}
+}
+
+// runDiag implements "vecu diag": send one OBD-II or UDS request, given as
+// hex bytes starting with the service ID, and print the response.
+func runDiag(args []string) error {
+	fs := flag.NewFlagSet("diag", flag.ExitOnError)
+	iface := fs.String("iface", "vcan0", "CAN interface to use")
+	timeout := fs.Duration("timeout", time.Second, "how long to wait for the response")
+	physical := fs.Bool("physical", false, fmt.Sprintf("address the engine ECU (0x%x) instead of the functional ID (0x%x)", diagPhysicalRequestID, diagFunctionalRequestID))
+	fs.Parse(args)
+
+	payload, err := hex.DecodeString(strings.Join(fs.Args(), ""))
+	if err != nil || len(payload) == 0 || len(payload) > 7 {
+		return fmt.Errorf("usage: diag [flags] <service> [data...], 1 to 7 hex bytes (e.g. diag 01 0c)")
+	}
+	req := can.Frame{ID: diagFunctionalRequestID, Length: 8}
+	if *physical {
+		req.ID = diagPhysicalRequestID
+	}
+	req.Data[0] = isoTPSingleFrame<<4 | byte(len(payload))
+	copy(req.Data[1:], payload)
+
+	start := time.Now()
+	resp, err := requestResponse(context.Background(), *iface, req, diagResponseID, *timeout)
+	if err != nil {
+		return err
+	}
+	elapsed := time.Since(start).Round(time.Microsecond)
+
+	data := resp.Data[:resp.Length]
+	switch {
+	case resp.Data[0]>>4 == isoTPFirstFrame:
+		log.Printf("Multi-frame response after %s, first frame: % x", elapsed, data)
+	case len(data) >= 4 && data[1] == negativeResponse:
+		log.Printf("Negative response after %s: service 0x%02x, NRC 0x%02x", elapsed, data[2], data[3])
+	default:
+		length := min(int(data[0]&0x0F), len(data)-1)
+		log.Printf("Response after %s: % x", elapsed, data[1:1+length])
+	}
+	return nil
+}
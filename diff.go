@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// runSeries is one signal's values over a captured run, in time order.
+type runSeries struct {
+	times  []float64 // Seconds since the first frame of the run
+	values []float64
+}
+
+// at returns the value at t, linearly interpolated between the samples
+// either side. It reports false outside the span of the series.
+func (s *runSeries) at(t float64) (float64, bool) {
+	i := sort.SearchFloat64s(s.times, t)
+	switch {
+	case i == len(s.times):
+		return 0, false
+	case s.times[i] == t:
+		return s.values[i], true
+	case i == 0:
+		return 0, false
+	}
+	t0, t1 := s.times[i-1], s.times[i]
+	v0, v1 := s.values[i-1], s.values[i]
+	return v0 + (v1-v0)*(t-t0)/(t1-t0), true
+}
+
+// add appends a sample at t, replacing the last one if it is at the same
+// instant.
+func (s *runSeries) add(t, value float64) {
+	if n := len(s.times); n > 0 && s.times[n-1] == t {
+		s.values[n-1] = value
+		return
+	}
+	s.times = append(s.times, t)
+	s.values = append(s.values, value)
+}
+
+// loadRunLog reads a captured run in candump log format, as written by
+// candump -l or the frame ring dump:
+//
+//	(1700000000.000000) vcan0 200#0064
+//
+// and decodes every frame with the message definitions into one series
+// per physical value, keyed as in SignalChange. Frames with IDs missing
+// from the DBC carry no values and are skipped, as are frames too short
+// for their message or failing its checksum, which are counted in a
+// warning. Times are rebased so the run starts at zero.
+func loadRunLog(path string) (map[string]*runSeries, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	series := make(map[string]*runSeries)
+	invalid := 0
+	start, last := math.NaN(), math.Inf(-1)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		stamp, _, _ := strings.Cut(text, " ")
+		if !strings.HasPrefix(stamp, "(") || !strings.HasSuffix(stamp, ")") {
+			return nil, fmt.Errorf("%s:%d: expected a candump log line starting with a (timestamp)", path, line)
+		}
+		t, err := strconv.ParseFloat(strings.Trim(stamp, "()"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid timestamp %s", path, line, stamp)
+		}
+		frame, err := parseCandumpLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if math.IsNaN(start) {
+			start = t
+		}
+		if t -= start; t < last {
+			return nil, fmt.Errorf("%s:%d: time goes backwards", path, line)
+		}
+		last = t
+
+		// Extended IDs are those above the 11-bit range, as in the DBC export
+		msg, ok := MessageByID(frame.ID)
+		if !ok || frame.IsExtended != (msg.ID > 0x7FF) {
+			continue
+		}
+		data := frame.Data[:frame.Length]
+		if frame.Length < msg.DataLen || !msg.verifyChecksum(data) {
+			invalid++
+			continue
+		}
+		for name, value := range msg.physicalValues(data[:msg.DataLen]) {
+			s := series[name]
+			if s == nil {
+				s = &runSeries{}
+				series[name] = s
+			}
+			s.add(t, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if invalid > 0 {
+		log.Printf("Warning: %s: skipped %d frames that are short or fail their checksum", path, invalid)
+	}
+	return series, nil
+}
+
+// divergence summarizes how one signal differs between two runs.
+type divergence struct {
+	Signal      string
+	Compared    int     // Samples of run A inside the span of run B
+	Max         float64 // Largest absolute difference
+	MaxAt       float64 // Seconds into the run
+	Mean        float64
+	Beyond      int     // Samples differing by more than the tolerance
+	FirstBeyond float64 // Seconds into the run of the first such sample
+}
+
+// compareSeries compares a signal at every sample of run A against run B
+// at the same time into the run.
+func compareSeries(name string, a, b *runSeries, tolerance float64) divergence {
+	d := divergence{Signal: name}
+	sum := 0.0
+	for i, t := range a.times {
+		other, ok := b.at(t)
+		if !ok {
+			continue
+		}
+		diff := math.Abs(a.values[i] - other)
+		d.Compared++
+		sum += diff
+		if diff > d.Max {
+			d.Max, d.MaxAt = diff, t
+		}
+		if diff > tolerance {
+			if d.Beyond == 0 {
+				d.FirstBeyond = t
+			}
+			d.Beyond++
+		}
+	}
+	if d.Compared > 0 {
+		d.Mean = sum / float64(d.Compared)
+	}
+	return d
+}
+
+// parseTolerances parses a -tolerance value like "0.5,EngineRPM=50": the
+// default allowed difference and per-signal overrides.
+func parseTolerances(spec string) (float64, map[string]float64, error) {
+	tolerance, overrides := 0.0, make(map[string]float64)
+	for _, item := range strings.Split(spec, ",") {
+		name, value, override := strings.Cut(strings.TrimSpace(item), "=")
+		if !override {
+			value = name
+		}
+		t, err := strconv.ParseFloat(value, 64)
+		if err != nil || t < 0 {
+			return 0, nil, fmt.Errorf("invalid tolerance %q", item)
+		}
+		if override {
+			overrides[name] = t
+		} else {
+			tolerance = t
+		}
+	}
+	return tolerance, overrides, nil
+}
+
+// runDiff implements "vecu diff": decode two candump logs of captured runs
+// with the message definitions, compare their signal values over time and
+// fail if any signal diverges beyond its tolerance, summarizing the
+// maximum and mean divergence per signal. Runs are read as candump logs
+// rather than CSV because that is what the simulator and candump record;
+// nothing here writes decoded signals to CSV.
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	toleranceSpec := fs.String("tolerance", "0", "allowed absolute difference, with per-signal overrides, as tol[,Signal=tol,...] (e.g. 0.5,EngineRPM=50)")
+	dbcPath := fs.String("dbc", "", "also load message definitions from this DBC file")
+	aliasSpec := fs.String("alias", "", "rename imported DBC messages and signals as Old->New,...")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: diff [flags] <runA.log> <runB.log>")
+	}
+	tolerance, overrides, err := parseTolerances(*toleranceSpec)
+	if err != nil {
+		return err
+	}
+	var aliases map[string]string
+	if *aliasSpec != "" {
+		if aliases, err = parseAliases(*aliasSpec); err != nil {
+			return err
+		}
+	}
+	if err := loadMessages(*dbcPath, aliases); err != nil {
+		return err
+	}
+	pathA, pathB := fs.Arg(0), fs.Arg(1)
+	runA, err := loadRunLog(pathA)
+	if err != nil {
+		return err
+	}
+	runB, err := loadRunLog(pathB)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(runA))
+	for name := range runA {
+		names = append(names, name)
+	}
+	for name := range runB {
+		if _, ok := runA[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	failed := 0
+	for _, name := range names {
+		a, b := runA[name], runB[name]
+		switch {
+		case a == nil:
+			failed++
+			log.Printf("DIFF %s: only in %s", name, pathB)
+			continue
+		case b == nil:
+			failed++
+			log.Printf("DIFF %s: only in %s", name, pathA)
+			continue
+		}
+		limit, ok := overrides[name]
+		if !ok {
+			limit = tolerance
+		}
+		d := compareSeries(name, a, b, limit)
+		switch {
+		case d.Compared == 0:
+			failed++
+			log.Printf("DIFF %s: runs do not overlap in time", name)
+		case d.Beyond > 0:
+			failed++
+			log.Printf("DIFF %s: %d of %d samples beyond %g, first at %.3fs; max %.6g at %.3fs, mean %.6g", name, d.Beyond, d.Compared, limit, d.FirstBeyond, d.Max, d.MaxAt, d.Mean)
+		default:
+			log.Printf("SAME %s: %d samples, max %.6g, mean %.6g", name, d.Compared, d.Max, d.Mean)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("runs differ: %d of %d signals diverge", failed, len(names))
+	}
+	log.Printf("Runs match: all %d signals within tolerance", len(names))
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRunSeriesAt(t *testing.T) {
+	s := &runSeries{times: []float64{0, 1, 3}, values: []float64{10, 20, 0}}
+	tests := []struct {
+		t      float64
+		want   float64
+		wantOK bool
+	}{
+		{t: 0, want: 10, wantOK: true},
+		{t: 0.5, want: 15, wantOK: true},
+		{t: 1, want: 20, wantOK: true},
+		{t: 2.5, want: 5, wantOK: true},
+		{t: 3, want: 0, wantOK: true},
+		{t: -0.1},
+		{t: 3.1},
+	}
+	for _, tt := range tests {
+		got, ok := s.at(tt.t)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("at(%g) = %g, %t, want %g, %t", tt.t, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCompareSeries(t *testing.T) {
+	a := &runSeries{times: []float64{0, 1, 2, 3}, values: []float64{800, 900, 1000, 1100}}
+	b := &runSeries{times: []float64{0, 2}, values: []float64{800, 1040}}
+	got := compareSeries("EngineRPM", a, b, 30)
+	// At t=1 run B interpolates to 920; t=3 is outside run B
+	want := divergence{Signal: "EngineRPM", Compared: 3, Max: 40, MaxAt: 2, Mean: 20, Beyond: 1, FirstBeyond: 2}
+	if got != want {
+		t.Errorf("compareSeries() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadRunLog(t *testing.T) {
+	if err := loadMessages("", nil); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "run.log")
+	log := `(100.000000) vcan0 200#005A000000000000
+(100.500000) vcan0 200#00
+(100.600000) vcan0 7FF#00
+(100.700000) vcan0 00000200#005F000000000000
+(101.000000) vcan0 200#005C000000000000
+`
+	if err := os.WriteFile(path, []byte(log), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	series, err := loadRunLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := series["EngineTempSensor"]
+	if s == nil {
+		t.Fatalf("no EngineTempSensor series in %v", series)
+	}
+	// The short frame, the unknown ID and the extended frame are skipped
+	if want := []float64{0, 1}; !slices.Equal(s.times, want) {
+		t.Errorf("times = %v, want %v", s.times, want)
+	}
+	if want := []float64{90, 92}; !slices.Equal(s.values, want) {
+		t.Errorf("values = %v, want %v", s.values, want)
+	}
+}
+
+func TestParseTolerances(t *testing.T) {
+	tolerance, overrides, err := parseTolerances("0.5,EngineRPM=50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tolerance != 0.5 || len(overrides) != 1 || overrides["EngineRPM"] != 50 {
+		t.Errorf("parseTolerances() = %g, %v, want 0.5, map[EngineRPM:50]", tolerance, overrides)
+	}
+	for _, spec := range []string{"-1", "EngineRPM=fast", ""} {
+		if _, _, err := parseTolerances(spec); err == nil {
+			t.Errorf("parseTolerances(%q) succeeded, want an error", spec)
+		}
+	}
+}
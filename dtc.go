@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// storedDTC is a diagnostic trouble code latched when its plausibility
+// fault was first detected. It stays stored after the fault goes away,
+// until cleared.
+type storedDTC struct {
+	Code  uint8     `json:"code"`
+	SetAt time.Time `json:"set_at"`
+}
+
+// injectedDTC is an SAE trouble code set by hand through the API. It
+// stands for a fault that is present until it is injected inactive.
+type injectedDTC struct {
+	Code  string    `json:"code"`
+	SetAt time.Time `json:"set_at"`
+}
+
+// dtcManager latches fault codes and holds injected codes. Access is
+// guarded by simulationMux.
+type dtcManager struct {
+	stored   map[uint8]time.Time
+	injected map[string]time.Time
+}
+
+var dtcs = dtcManager{stored: make(map[uint8]time.Time), injected: make(map[string]time.Time)}
+
+// saeDTC matches an SAE J2012 trouble code such as P0301: the system
+// (Powertrain, Chassis, Body or network) and four hex digits.
+var saeDTC = regexp.MustCompile(`^[PCBU][0-9A-F]{4}$`)
+
+// parseDTC validates a trouble code, ignoring case, and returns it in its
+// canonical upper-case form.
+func parseDTC(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !saeDTC.MatchString(code) {
+		return "", fmt.Errorf("invalid DTC %q, expected P, C, B or U and four hex digits", code)
+	}
+	return code, nil
+}
+
+// inject sets code as present from now, keeping its set time if it is
+// already set.
+func (m *dtcManager) inject(code string, now time.Time) {
+	if _, ok := m.injected[code]; !ok {
+		m.injected[code] = now
+	}
+}
+
+// remove clears an injected code and reports whether it was set.
+func (m *dtcManager) remove(code string) bool {
+	_, ok := m.injected[code]
+	delete(m.injected, code)
+	return ok
+}
+
+// listInjected returns the injected codes in code order.
+func (m *dtcManager) listInjected() []injectedDTC {
+	list := make([]injectedDTC, 0, len(m.injected))
+	for code, at := range m.injected {
+		list = append(list, injectedDTC{Code: code, SetAt: at})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// record stores any of codes not already stored, as set at now.
+func (m *dtcManager) record(codes []uint8, now time.Time) {
+	for _, code := range codes {
+		if _, ok := m.stored[code]; !ok {
+			m.stored[code] = now
+		}
+	}
+}
+
+// list returns the stored codes in code order.
+func (m *dtcManager) list() []storedDTC {
+	list := make([]storedDTC, 0, len(m.stored))
+	for code, at := range m.stored {
+		list = append(list, storedDTC{Code: code, SetAt: at})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// clear forgets every latched code and returns how many codes there
+// were. Injected codes are still present, so they are set again from now.
+func (m *dtcManager) clear(now time.Time) int {
+	n := len(m.stored) + len(m.injected)
+	clear(m.stored)
+	for code := range m.injected {
+		m.injected[code] = now
+	}
+	return n
+}
+
+// reset forgets every code, latched or injected.
+func (m *dtcManager) reset() {
+	clear(m.stored)
+	clear(m.injected)
+}
+
+// clearDTCs clears the stored codes as a diagnostic tool would. A fault
+// that is still present sets its code again straight away, with a new set
+// time.
+func clearDTCs() {
+	simulationMux.Lock()
+	state := vehicle
+	state.applyOverrides()
+	now := time.Now()
+	cleared := dtcs.clear(now)
+	injected := len(dtcs.injected)
+	var present []uint8
+	if engineOn {
+		for _, rule := range checkPlausibility(state) {
+			present = append(present, rule.FaultCode)
+		}
+	}
+	dtcs.record(present, now)
+	simulationMux.Unlock()
+
+	log.Printf("Cleared %d DTCs, %d still present and set again", cleared, len(present)+injected)
+}
+
+// faultName describes a fault code with its rule name, if it has one.
+func faultName(code uint8) string {
+	name := fmt.Sprintf("0x%02x", code)
+	if rule, ok := plausibilityRuleByCode(code); ok {
+		name += " " + rule.Name
+	}
+	return name
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// duplicateDetector flags a frame whose ID and payload exactly match the
+// previous frame with that ID when it arrives within window, the signature
+// of a transmitter double-sending. It is a sink, so it is only used from
+// the receive loop and needs no locking.
+type duplicateDetector struct {
+	window time.Duration
+	last   map[uint32]recordedFrame
+}
+
+func newDuplicateDetector(window time.Duration) *duplicateDetector {
+	return &duplicateDetector{window: window, last: make(map[uint32]recordedFrame)}
+}
+
+func (d *duplicateDetector) OnReceive(frame can.Frame, decoded string) {
+	now := time.Now()
+	prev, seen := d.last[frame.ID]
+	d.last[frame.ID] = recordedFrame{At: now, Frame: frame}
+	if seen && prev.Frame == frame {
+		if gap := now.Sub(prev.At); gap < d.window {
+			warnFrame(frame, "Frame ID 0x%x suspected duplicate: identical frame %s after the previous one", frame.ID, gap)
+			stats.recordDuplicate(frame.ID)
+		}
+	}
+}
+
+func (d *duplicateDetector) OnTransmit(frame can.Frame) {}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// engineStatus is the actual engine state, broadcast by the EngineStatus
+// message as feedback for the EngineOnOff command.
+type engineStatus uint8
+
+const (
+	engineStatusOff engineStatus = iota
+	engineStatusCranking
+	engineStatusRunning
+	engineStatusStopping // Spinning down after switch-off
+	engineStatusStalled
+)
+
+// engineStatusInterval is the EngineStatus cadence, faster than the
+// default so consumers see a start or stall promptly.
+const engineStatusInterval = 100 * time.Millisecond
+
+func (s engineStatus) String() string {
+	switch s {
+	case engineStatusOff:
+		return "Off"
+	case engineStatusCranking:
+		return "Cranking"
+	case engineStatusRunning:
+		return "Running"
+	case engineStatusStopping:
+		return "Stopping"
+	case engineStatusStalled:
+		return "Stalled"
+	}
+	return fmt.Sprintf("Unknown (%d)", uint8(s))
+}
+
+// engineStatus derives the engine state from the model. A stall is
+// reported until the engine is restarted or the ignition turned off.
+func (v vehicleModel) engineStatus() engineStatus {
+	switch {
+	case v.running && v.ignition == ignitionCrank:
+		return engineStatusCranking
+	case v.running:
+		return engineStatusRunning
+	case v.stalled && v.ignition >= ignitionRun:
+		return engineStatusStalled
+	case v.coasting():
+		return engineStatusStopping
+	}
+	return engineStatusOff
+}
+
+func decodeEngineStatus(data []byte) string {
+	return fmt.Sprintf("Engine Status: %s", engineStatus(data[0]))
+}
+
+func encodeEngineStatus(v vehicleModel) [8]byte {
+	return [8]byte{byte(v.engineStatus())}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"go.einride.tech/can"
+)
+
+// errorLog receives a copy of every frame warning so problems can be triaged
+// without the surrounding traffic. It discards output unless -errlog is set.
+var errorLog = log.New(io.Discard, "", log.LstdFlags|log.Lmicroseconds)
+
+// openErrorLog directs frame warnings to the file at path, appending to it.
+func openErrorLog(path string) (io.Closer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open error log: %w", err)
+	}
+	errorLog.SetOutput(f)
+	return f, nil
+}
+
+// warnFrame logs a warning about a received frame to the main log and tees
+// it, together with the offending frame, to the error log.
+func warnFrame(frame can.Frame, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.Print(msg)
+	errorLog.Printf("%s	frame=%s", msg, frame.String())
+}
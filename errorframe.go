@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go.einride.tech/can"
+	"go.einride.tech/can/pkg/socketcan"
+)
+
+// canErrFlag marks a SocketCAN frame as an error frame (CAN_ERR_FLAG).
+const canErrFlag = 0x20000000
+
+// Error frame injection is requested with a 0x102 ErrorInject control frame.
+// Byte 0 selects the error class and bytes 1-7 are copied verbatim into the
+// error frame payload, so e.g. a controller error carries its detail code in
+// byte 1 and a protocol violation its type and location in bytes 2 and 3.
+//
+// Supported classes are the ones that fit in byte 0:
+//
+//	0x01 TxTimeout, 0x02 LostArbitration, 0x04 Controller,
+//	0x08 ProtocolViolation, 0x10 Transceiver, 0x20 NoAck,
+//	0x40 BusOff, 0x80 BusError
+//
+// SocketCAN only delivers error frames to sockets that enabled them, and
+// whether a real controller forwards a user-written error frame is driver
+// specific; on vcan the frame is looped back to every listener.
+var injectableErrorClasses = []socketcan.ErrorClass{
+	socketcan.ErrorClassTxTimeout,
+	socketcan.ErrorClassLostArbitration,
+	socketcan.ErrorClassController,
+	socketcan.ErrorClassProtocolViolation,
+	socketcan.ErrorClassTransceiver,
+	socketcan.ErrorClassNoAck,
+	socketcan.ErrorClassBusOff,
+	socketcan.ErrorClassBusError,
+}
+
+// errorClassFromCommand validates the error class requested in byte 0.
+func errorClassFromCommand(b byte) (socketcan.ErrorClass, error) {
+	for _, class := range injectableErrorClasses {
+		if socketcan.ErrorClass(b) == class {
+			return class, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported error class 0x%02x", b)
+}
+
+func decodeErrorInject(data []byte) string {
+	class, err := errorClassFromCommand(data[0])
+	if err != nil {
+		return fmt.Sprintf("Error Injection: %v", err)
+	}
+	return fmt.Sprintf("Error Injection: %s", class)
+}
+
+// injectErrorFrame puts an error frame of the requested class on the bus
+// through the message injector, so it is counted, confirmed and retried
+// like any other transmitted frame.
+func injectErrorFrame(ctx context.Context, command can.Frame) {
+	class, err := errorClassFromCommand(command.Data[0])
+	if err != nil {
+		log.Printf("Error injection rejected: %v", err)
+		return
+	}
+
+	errFrame := can.Frame{ID: canErrFlag | uint32(class), Length: 8}
+	copy(errFrame.Data[1:], command.Data[1:])
+	if err := injector.InjectFrame(ctx, errFrame); err != nil {
+		log.Printf("failed to inject %s error frame: %v", class, err)
+		return
+	}
+	log.Printf("Injected %s error frame %v", class, errFrame.Data)
+}
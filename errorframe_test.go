@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.einride.tech/can"
+)
+
+func TestInjectErrorFrame(t *testing.T) {
+	dialer := &fakeDialer{}
+	tx, err := dialTransmitterWith(context.Background(), dialer, "vcan0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := injector
+	injector = newMessageInjector(tx)
+	t.Cleanup(func() { injector = saved })
+
+	// A bus-off error, and an unsupported class that is rejected
+	injectErrorFrame(context.Background(), can.Frame{ID: 0x102, Length: 3, Data: can.Data{0x40, 0x12, 0x34}})
+	injectErrorFrame(context.Background(), can.Frame{ID: 0x102, Length: 1, Data: can.Data{0x03}})
+
+	want := can.Frame{ID: canErrFlag | 0x40, Length: 8, Data: can.Data{0, 0x12, 0x34}}
+	if sent := dialer.senders[0].sent; len(sent) != 1 || sent[0] != want {
+		t.Errorf("sent = %v, want [%v]", sent, want)
+	}
+}
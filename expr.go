@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// expr is a parsed arithmetic expression, set on a physical-value message
+// with -expr. Variables are t, the model time in seconds since startup,
+// and the model readings by message name (see sensorValues).
+type expr interface {
+	eval(vars map[string]float64) float64
+}
+
+type (
+	exprNumber float64
+	exprVar    string
+	exprNeg    struct{ x expr }
+	exprBinary struct {
+		op   byte
+		l, r expr
+	}
+	exprCall struct {
+		fn   func(args []float64) float64
+		args []expr
+	}
+)
+
+func (n exprNumber) eval(map[string]float64) float64   { return float64(n) }
+func (v exprVar) eval(vars map[string]float64) float64 { return vars[string(v)] }
+
+func (n exprNeg) eval(vars map[string]float64) float64 { return -n.x.eval(vars) }
+
+func (b exprBinary) eval(vars map[string]float64) float64 {
+	l, r := b.l.eval(vars), b.r.eval(vars)
+	switch b.op {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	case '/':
+		return l / r
+	case '%':
+		return math.Mod(l, r)
+	}
+	return math.Pow(l, r)
+}
+
+func (c exprCall) eval(vars map[string]float64) float64 {
+	args := make([]float64, len(c.args))
+	for i, a := range c.args {
+		args[i] = a.eval(vars)
+	}
+	return c.fn(args)
+}
+
+// exprFunc is a function callable from an expression and its arity.
+type exprFunc struct {
+	arity int
+	fn    func(args []float64) float64
+}
+
+func unary(f func(float64) float64) exprFunc {
+	return exprFunc{1, func(a []float64) float64 { return f(a[0]) }}
+}
+
+var exprFuncs = map[string]exprFunc{
+	"sin":   unary(math.Sin),
+	"cos":   unary(math.Cos),
+	"abs":   unary(math.Abs),
+	"sqrt":  unary(math.Sqrt),
+	"floor": unary(math.Floor),
+	"min":   {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+	// square(x) is a unit square wave with period 2π, like sin
+	"square": unary(func(x float64) float64 { return math.Copysign(1, math.Sin(x)) }),
+}
+
+// exprParser is a recursive-descent parser over the grammar
+//
+//	sum     = product { ("+" | "-") product }
+//	product = unary { ("*" | "/" | "%") unary }
+//	unary   = "-" unary | power
+//	power   = primary [ "^" unary ]
+//	primary = number | name | name "(" sum { "," sum } ")" | "(" sum ")"
+type exprParser struct {
+	src string
+	pos int
+}
+
+// parseExpr parses an expression such as "2000 + 500*sin(t/5)".
+func parseExpr(src string) (expr, error) {
+	p := &exprParser{src: src}
+	e, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return e, nil
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("expression %q at %d: %s", p.src, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// accept consumes the next character if it is one of ops.
+func (p *exprParser) accept(ops string) (byte, bool) {
+	p.skipSpace()
+	if p.pos < len(p.src) && strings.IndexByte(ops, p.src[p.pos]) >= 0 {
+		p.pos++
+		return p.src[p.pos-1], true
+	}
+	return 0, false
+}
+
+func (p *exprParser) sum() (expr, error) {
+	e, err := p.product()
+	for err == nil {
+		op, ok := p.accept("+-")
+		if !ok {
+			break
+		}
+		var r expr
+		if r, err = p.product(); err == nil {
+			e = exprBinary{op, e, r}
+		}
+	}
+	return e, err
+}
+
+func (p *exprParser) product() (expr, error) {
+	e, err := p.unary()
+	for err == nil {
+		op, ok := p.accept("*/%")
+		if !ok {
+			break
+		}
+		var r expr
+		if r, err = p.unary(); err == nil {
+			e = exprBinary{op, e, r}
+		}
+	}
+	return e, err
+}
+
+func (p *exprParser) power() (expr, error) {
+	e, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("^"); ok {
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return exprBinary{'^', e, r}, nil
+	}
+	return e, nil
+}
+
+func (p *exprParser) unary() (expr, error) {
+	if _, ok := p.accept("-"); ok {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return exprNeg{x}, nil
+	}
+	return p.power()
+}
+
+func (p *exprParser) primary() (expr, error) {
+	p.skipSpace()
+	if _, ok := p.accept("("); ok {
+		e, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, p.errorf("missing )")
+		}
+		return e, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && (unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos])) || p.src[p.pos] == '.' || p.src[p.pos] == '_') {
+		p.pos++
+	}
+	token := p.src[start:p.pos]
+	switch {
+	case token == "":
+		return nil, p.errorf("expected a number, name or (")
+	case unicode.IsDigit(rune(token[0])) || token[0] == '.':
+		n, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", token)
+		}
+		return exprNumber(n), nil
+	}
+
+	if _, ok := p.accept("("); !ok {
+		if token != "t" {
+			if _, known := (vehicleModel{}).sensorValues()[token]; !known {
+				return nil, p.errorf("unknown variable %q", token)
+			}
+		}
+		return exprVar(token), nil
+	}
+	f, ok := exprFuncs[token]
+	if !ok {
+		return nil, p.errorf("unknown function %q", token)
+	}
+	var args []expr
+	for {
+		arg, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if _, ok := p.accept(","); !ok {
+			break
+		}
+	}
+	if _, ok := p.accept(")"); !ok {
+		return nil, p.errorf("missing ) after the arguments of %s", token)
+	}
+	if len(args) != f.arity {
+		return nil, p.errorf("%s takes %d arguments, got %d", token, f.arity, len(args))
+	}
+	return exprCall{f.fn, args}, nil
+}
+
+// exprValue returns a message Value computed from an expression over the
+// model readings and the model time. A result that is not a number, such
+// as a division by zero, is transmitted as 0.
+func exprValue(e expr) func(v vehicleModel) float64 {
+	return func(v vehicleModel) float64 {
+		vars := v.sensorValues()
+		vars["t"] = modelDuration(time.Since(clockEpoch)).Seconds()
+		value := e.eval(vars)
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return 0
+		}
+		return value
+	}
+}
+
+// applyExpressions replaces the value of physical-value messages with the
+// expressions of -expr values like "EngineRPM=2000 + 500*sin(t/5)".
+func applyExpressions(specs []string) error {
+	for _, spec := range specs {
+		name, src, ok := strings.Cut(spec, "=")
+		if !ok {
+			return fmt.Errorf("invalid expression %q, expected Name=expression", spec)
+		}
+		msg, found := MessageByName(strings.TrimSpace(name))
+		if !found || msg.Value == nil {
+			return fmt.Errorf("cannot compute %q from an expression: not a physical-value sensor", name)
+		}
+		e, err := parseExpr(src)
+		if err != nil {
+			return err
+		}
+		msg.Value = exprValue(e)
+		updateMessage(msg)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// faultStep is one timed entry of a fault-injection timeline.
+type faultStep struct {
+	At       time.Duration // Offset from the start of the timeline
+	Action   string        // "stuck", "dropout" or "clear"
+	Message  string        // Message name the step applies to
+	Duration time.Duration // Dropout length, 0 until cleared
+	Line     int           // Source line, for logging
+}
+
+// dropouts holds messages that are withheld from the bus, mapped to when
+// the dropout ends (zero until cleared). Guarded by simulationMux.
+var dropouts = make(map[string]time.Time)
+
+// droppedOut reports whether a message is currently withheld from the bus.
+func droppedOut(name string, now time.Time) bool {
+	simulationMux.Lock()
+	defer simulationMux.Unlock()
+	until, ok := dropouts[name]
+	if ok && !until.IsZero() && !now.Before(until) {
+		delete(dropouts, name)
+		return false
+	}
+	return ok
+}
+
+// loadFaultTimeline reads a timeline file with one step per line:
+//
+//	at 10s inject stuck EngineTempSensor
+//	at 20s clear EngineTempSensor
+//	at 25s inject dropout EngineRPM 5s
+//
+// Blank lines and lines starting with # are ignored.
+func loadFaultTimeline(path string) ([]faultStep, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var steps []faultStep
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		step, err := parseFaultStep(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		step.Line = line
+		steps = append(steps, step)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Steps at the same time keep their file order
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].At < steps[j].At })
+	return steps, nil
+}
+
+// parseFaultStep parses a single timeline line.
+func parseFaultStep(text string) (faultStep, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 4 || fields[0] != "at" {
+		return faultStep{}, fmt.Errorf("expected \"at <time> inject|clear ...\", got %q", text)
+	}
+	at, err := time.ParseDuration(fields[1])
+	if err != nil || at < 0 {
+		return faultStep{}, fmt.Errorf("invalid time %q", fields[1])
+	}
+
+	var step faultStep
+	switch fields[2] {
+	case "clear":
+		if len(fields) != 4 {
+			return faultStep{}, fmt.Errorf("expected \"clear <message>\"")
+		}
+		step = faultStep{At: at, Action: "clear", Message: fields[3]}
+	case "inject":
+		if len(fields) < 5 {
+			return faultStep{}, fmt.Errorf("expected \"inject stuck|dropout <message>\"")
+		}
+		step = faultStep{At: at, Action: fields[3], Message: fields[4]}
+		switch step.Action {
+		case "stuck":
+			if len(fields) != 5 {
+				return faultStep{}, fmt.Errorf("unexpected arguments after stuck %s", step.Message)
+			}
+			if _, ok := sensorFields[step.Message]; !ok {
+				return faultStep{}, fmt.Errorf("%s cannot be stuck, not an overridable sensor", step.Message)
+			}
+		case "dropout":
+			if len(fields) > 6 {
+				return faultStep{}, fmt.Errorf("unexpected arguments after dropout %s", step.Message)
+			}
+			if len(fields) == 6 {
+				if step.Duration, err = time.ParseDuration(fields[5]); err != nil || step.Duration <= 0 {
+					return faultStep{}, fmt.Errorf("invalid dropout duration %q", fields[5])
+				}
+			}
+		default:
+			return faultStep{}, fmt.Errorf("unknown fault %q, expected stuck or dropout", step.Action)
+		}
+	default:
+		return faultStep{}, fmt.Errorf("unknown action %q, expected inject or clear", fields[2])
+	}
+
+	if _, ok := MessageByName(step.Message); !ok {
+		return faultStep{}, fmt.Errorf("unknown message %q", step.Message)
+	}
+	return step, nil
+}
+
+// runFaultTimeline applies each step at its offset from when it is called,
+// until the timeline ends or ctx is cancelled.
+func runFaultTimeline(ctx context.Context, steps []faultStep) {
+	start := time.Now()
+	for _, step := range steps {
+		if !sleepUntil(ctx, start.Add(step.At)) {
+			return
+		}
+		step.apply(time.Now())
+	}
+}
+
+// apply performs a timeline step.
+func (s faultStep) apply(now time.Time) {
+	switch s.Action {
+	case "stuck":
+		// Freeze the sensor at whatever it is reporting right now
+		value, _ := SignalValue(s.Message)
+		setOverride(s.Message, value)
+		log.Printf("Fault timeline (line %d): %s stuck at %g", s.Line, s.Message, value)
+	case "dropout":
+		var until time.Time
+		if s.Duration > 0 {
+			until = now.Add(s.Duration)
+		}
+		simulationMux.Lock()
+		dropouts[s.Message] = until
+		simulationMux.Unlock()
+		log.Printf("Fault timeline (line %d): %s dropped out", s.Line, s.Message)
+	case "clear":
+		clearOverride(s.Message)
+		simulationMux.Lock()
+		delete(dropouts, s.Message)
+		simulationMux.Unlock()
+		log.Printf("Fault timeline (line %d): %s cleared", s.Line, s.Message)
+	}
+}
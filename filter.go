@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// filterAlphas holds the exponential moving average weight per message
+// name, set with -smooth. Messages without an entry use alpha 1, which
+// transmits the model value unfiltered.
+var filterAlphas = map[string]float64{}
+
+// parseFilterAlphas parses a -smooth value like "EngineRPM=0.3,OxygenSensor=0.5".
+func parseFilterAlphas(spec string) (map[string]float64, error) {
+	alphas := make(map[string]float64)
+	for _, item := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid smoothing %q, expected Name=alpha", item)
+		}
+		if msg, found := MessageByName(name); !found || msg.Value == nil {
+			return nil, fmt.Errorf("cannot smooth %q: not a physical-value sensor", name)
+		}
+		alpha, err := strconv.ParseFloat(value, 64)
+		if err != nil || alpha <= 0 || alpha > 1 {
+			return nil, fmt.Errorf("invalid alpha %q for %s, expected 0 < alpha <= 1", value, name)
+		}
+		alphas[name] = alpha
+	}
+	return alphas, nil
+}
+
+// signalFilter low-pass filters transmitted values, remembering the last
+// output per message. Each transmit loop owns its own filter.
+type signalFilter struct {
+	prev map[string]float64
+}
+
+func newSignalFilter() *signalFilter {
+	return &signalFilter{prev: make(map[string]float64)}
+}
+
+// apply returns the filtered value for the named message. The first value
+// seen passes through unchanged to seed the average.
+func (f *signalFilter) apply(name string, value float64) float64 {
+	alpha, ok := filterAlphas[name]
+	if !ok {
+		return value
+	}
+	prev, seen := f.prev[name]
+	if seen {
+		value = alpha*value + (1-alpha)*prev
+	}
+	f.prev[name] = value
+	return value
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// rxGaps measures the gap between consecutive receptions of each message
+// ID, for checking another node against its timing budget. It is only used
+// from the receive loop.
+type rxGaps struct {
+	tolerance float64 // Allowed excess over the cycle time, as a fraction
+	last      map[uint32]time.Time
+}
+
+func newRxGaps(tolerance float64) *rxGaps {
+	return &rxGaps{tolerance: tolerance, last: make(map[uint32]time.Time)}
+}
+
+// observe logs the gap since the previous frame with the same ID. Gaps of
+// known messages that exceed their cycle time by more than the tolerance
+// are flagged as warnings.
+func (g *rxGaps) observe(frame can.Frame, now time.Time) {
+	prev, seen := g.last[frame.ID]
+	g.last[frame.ID] = now
+	if !seen {
+		return
+	}
+	gap := now.Sub(prev)
+
+	msg, known := MessageByID(frame.ID)
+	if !known {
+		log.Printf("Gap 0x%x: %s", frame.ID, gap.Round(time.Microsecond))
+		return
+	}
+	cycle := msg.interval()
+	if limit := time.Duration(float64(cycle) * (1 + g.tolerance)); gap > limit {
+		warnFrame(frame, "Frame ID 0x%x (%s) gap %s exceeds its %s cycle by more than %.0f%%", frame.ID, msg.Name, gap.Round(time.Microsecond), cycle, g.tolerance*100)
+		return
+	}
+	log.Printf("Gap 0x%x (%s): %s of %s cycle", frame.ID, msg.Name, gap.Round(time.Microsecond), cycle)
+}
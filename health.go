@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// handleStallTimeout is how long the receive loop may spend on one frame
+// before it is reported as hung.
+const handleStallTimeout = 5 * time.Second
+
+// receiveHealth tracks the liveness of the receive loop for GET /healthz.
+// The loop marks itself busy while handling a frame and waiting while
+// blocked on the bus, so a silent bus is healthy but a loop stuck on a
+// frame is not.
+type receiveHealth struct {
+	mu        sync.Mutex
+	connected bool
+	busySince time.Time // Zero while waiting for the next frame
+}
+
+var rxHealth receiveHealth
+
+// up records that the receive connection is open.
+func (h *receiveHealth) up() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.connected = true
+	h.busySince = time.Time{}
+}
+
+// down records that the receive loop has ended.
+func (h *receiveHealth) down() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.connected = false
+}
+
+// waiting records that the loop is blocked for the next frame.
+func (h *receiveHealth) waiting() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.busySince = time.Time{}
+}
+
+// busy records that the loop has started handling a frame.
+func (h *receiveHealth) busy(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.busySince = now
+}
+
+// check returns why the receive loop is unhealthy, or nil.
+func (h *receiveHealth) check(now time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.connected {
+		return errors.New("receive connection is down")
+	}
+	if !h.busySince.IsZero() && now.Sub(h.busySince) > handleStallTimeout {
+		return fmt.Errorf("receive loop stuck on a frame for %s", now.Sub(h.busySince).Round(time.Second))
+	}
+	return nil
+}
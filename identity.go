@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// vehicleIdentity distinguishes one simulated vehicle from another, set
+// with -vin and -ecu-serial or generated at startup.
+type vehicleIdentity struct {
+	VIN       string `json:"vin"`
+	ECUSerial string `json:"ecu_serial"`
+}
+
+// identity is the simulated vehicle's identity, generated once the random
+// seed is known.
+var identity vehicleIdentity
+
+// newVehicleIdentity returns a random identity drawn from the seeded
+// source.
+func newVehicleIdentity() vehicleIdentity {
+	return vehicleIdentity{VIN: randomVIN(), ECUSerial: randomECUSerial()}
+}
+
+// UDS data identifiers served by ReadDataByIdentifier.
+const (
+	didECUSerial = 0xF18C
+	didVIN       = 0xF190
+)
+
+// readDataByIdentifier returns the data record of a DID.
+func readDataByIdentifier(did uint16) ([]byte, bool) {
+	switch did {
+	case didECUSerial:
+		return []byte(identity.ECUSerial), true
+	case didVIN:
+		return []byte(identity.VIN), true
+	}
+	return nil, false
+}
+
+// vinChars are the characters allowed in a VIN: I, O and Q are excluded
+// to avoid confusion with 1 and 0.
+const vinChars = "ABCDEFGHJKLMNPRSTUVWXYZ0123456789"
+
+// vinWMIs are real world manufacturer identifiers to start generated VINs
+// with, so they look plausible to tools that decode them.
+var vinWMIs = []string{"1HG", "1FT", "JTD", "KMH", "VF1", "WBA", "WVW", "YV1"}
+
+// vinWeights are the check digit weights of each VIN position.
+var vinWeights = [17]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+// vinValue transliterates a VIN character for the check digit.
+func vinValue(c byte) (int, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0'), true
+	case c >= 'A' && c <= 'H':
+		return int(c-'A') + 1, true
+	case c >= 'J' && c <= 'N':
+		return int(c-'J') + 1, true
+	case c == 'P':
+		return 7, true
+	case c == 'R':
+		return 9, true
+	case c >= 'S' && c <= 'Z':
+		return int(c-'S') + 2, true
+	}
+	return 0, false
+}
+
+// vinCheckDigit computes the North American check digit (position 9) of a
+// 17-character VIN.
+func vinCheckDigit(vin string) (byte, error) {
+	if len(vin) != 17 {
+		return 0, fmt.Errorf("VIN %q is %d characters, expected 17", vin, len(vin))
+	}
+	sum := 0
+	for i := 0; i < len(vin); i++ {
+		value, ok := vinValue(vin[i])
+		if !ok || !strings.ContainsRune(vinChars, rune(vin[i])) {
+			return 0, fmt.Errorf("VIN %q has invalid character %q", vin, vin[i])
+		}
+		sum += value * vinWeights[i]
+	}
+	if sum%11 == 10 {
+		return 'X', nil
+	}
+	return byte('0' + sum%11), nil
+}
+
+// validateVIN checks the characters and check digit of a VIN.
+func validateVIN(vin string) error {
+	check, err := vinCheckDigit(vin)
+	if err != nil {
+		return err
+	}
+	if vin[8] != check {
+		return fmt.Errorf("VIN %q has check digit %c, expected %c", vin, vin[8], check)
+	}
+	return nil
+}
+
+// randomVIN generates a VIN with a valid check digit.
+func randomVIN() string {
+	vin := []byte(vinWMIs[rand.Intn(len(vinWMIs))])
+	for len(vin) < 17 {
+		vin = append(vin, vinChars[rand.Intn(len(vinChars))])
+	}
+	vin[8] = '0'
+	check, _ := vinCheckDigit(string(vin))
+	vin[8] = check
+	return string(vin)
+}
+
+// randomECUSerial generates an ECU serial number.
+func randomECUSerial() string {
+	return fmt.Sprintf("VECU%08d", rand.Intn(100000000))
+}
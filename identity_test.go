@@ -0,0 +1,18 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestNewVehicleIdentitySeeded(t *testing.T) {
+	rand.Seed(42)
+	first := newVehicleIdentity()
+	rand.Seed(42)
+	if again := newVehicleIdentity(); again != first {
+		t.Errorf("identity with the same seed = %+v, want %+v", again, first)
+	}
+	if err := validateVIN(first.VIN); err != nil {
+		t.Errorf("generated VIN %s: %v", first.VIN, err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// IgnitionState is the position of the ignition switch, as reported by the
+// KeyPosition message. The states are ordered, so each message can require
+// a minimum state to be transmitted.
+type IgnitionState uint8
+
+const (
+	ignitionOff IgnitionState = iota
+	ignitionAccessory
+	ignitionRun
+	ignitionCrank // Momentary, returns to Run once the engine is running
+)
+
+// ignitionNames maps the names accepted by -min-ignition to states.
+var ignitionNames = map[string]IgnitionState{
+	"off":       ignitionOff,
+	"accessory": ignitionAccessory,
+	"run":       ignitionRun,
+	"crank":     ignitionCrank,
+}
+
+func (s IgnitionState) String() string {
+	switch s {
+	case ignitionOff:
+		return "Off"
+	case ignitionAccessory:
+		return "Accessory"
+	case ignitionRun:
+		return "Run"
+	case ignitionCrank:
+		return "Crank"
+	}
+	return fmt.Sprintf("Unknown (%d)", uint8(s))
+}
+
+// setIgnition switches the ignition, from the 0x106 Ignition control frame.
+// Cranking starts the engine and dropping below Run stops it; Run itself
+// leaves the engine as it is.
+func setIgnition(ctx context.Context, state IgnitionState) {
+	switch {
+	case state == ignitionCrank:
+		setEngineState(ctx, true)
+	case state < ignitionRun:
+		setEngineState(ctx, false)
+	}
+
+	simulationMux.Lock()
+	changed := vehicle.ignition != state
+	vehicle.ignition = state
+	simulationMux.Unlock()
+	if changed {
+		log.Printf("Ignition %s", state)
+	}
+}
+
+func decodeIgnition(data []byte) string {
+	return fmt.Sprintf("Ignition: %s", IgnitionState(data[0]))
+}
+
+// applyMinIgnition sets the lowest ignition state each message is sent in
+// from a -min-ignition value like "AmbientTemp=accessory,WarningLamps=run".
+func applyMinIgnition(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return fmt.Errorf("invalid ignition requirement %q, expected Name=state", item)
+		}
+		msg, found := MessageByName(name)
+		if !found {
+			return fmt.Errorf("unknown message %q", name)
+		}
+		state, ok := ignitionNames[value]
+		if !ok {
+			return fmt.Errorf("invalid ignition state %q for %s, expected off, accessory, run or crank", value, name)
+		}
+		msg.MinIgnition = state
+		updateMessage(msg)
+	}
+	return nil
+}
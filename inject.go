@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"go.einride.tech/can"
+)
+
+// messageInjector transmits frames built from message definitions for the
+// HTTP API and in answer to remote frames. Requests arrive on their own
+// goroutines, so the transmitter, counters and filter are shared under mu.
+type messageInjector struct {
+	mu       sync.Mutex
+	tx       *busTransmitter
+	counters txCounters
+	filter   *signalFilter
+}
+
+// injector is the process-wide injector, set up before the API starts.
+var injector *messageInjector
+
+func newMessageInjector(tx *busTransmitter) *messageInjector {
+	return &messageInjector{tx: tx, counters: make(txCounters), filter: newSignalFilter()}
+}
+
+// InjectMessage encodes the named signal values into a frame for the named
+// message and transmits it. Values are in physical units; signals that are
+// not given are sent as zero. Physical-value messages take a single value
+// named after the message or "value". The checksum and rolling counter are
+// filled in as for simulated frames.
+func (j *messageInjector) InjectMessage(ctx context.Context, name string, values map[string]int) (can.Frame, error) {
+	msg, ok := MessageByName(name)
+	if !ok {
+		return can.Frame{}, fmt.Errorf("unknown message %q", name)
+	}
+	data, err := msg.encodeValues(values)
+	if err != nil {
+		return can.Frame{}, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	msg.applyCounter(&data, j.counters.next(msg))
+	msg.applyChecksum(&data)
+	frame := msg.frame(data)
+	return frame, j.tx.transmit(ctx, frame)
+}
+
+// InjectFrame transmits a frame as given, such as an error frame, through
+// the injector's transmitter.
+func (j *messageInjector) InjectFrame(ctx context.Context, frame can.Frame) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.tx.transmit(ctx, frame)
+}
+
+// encodeValues packs physical signal values into a payload, rejecting
+// unknown signals and values the signal cannot represent.
+func (m CANMessage) encodeValues(values map[string]int) ([8]byte, error) {
+	var data [8]byte
+	if m.Value != nil {
+		for sig := range values {
+			if sig != m.Name && sig != "value" {
+				return data, fmt.Errorf("%s has a single value, not signal %q", m.Name, sig)
+			}
+		}
+		value := float64(values["value"])
+		if v, ok := values[m.Name]; ok {
+			value = float64(v)
+		}
+		raw := math.Round((value - m.Offset) / m.factor())
+		if raw < 0 || raw > math.Pow(2, float64(8*m.ValueLen))-1 {
+			return data, fmt.Errorf("%s value %g out of range", m.Name, value)
+		}
+		return m.encodePhysical(value), nil
+	}
+
+	names := make([]string, 0, len(values))
+	for sig := range values {
+		names = append(names, sig)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sig, ok := m.signal(name)
+		if !ok {
+			return data, fmt.Errorf("%s has no signal %q", m.Name, name)
+		}
+		raw, err := sig.rawValue(float64(values[name]))
+		if err != nil {
+			return data, fmt.Errorf("%s.%s: %v", m.Name, name, err)
+		}
+		sig.insert(&data, raw)
+	}
+	return data, nil
+}
+
+// signal looks up a signal of the message by name.
+func (m CANMessage) signal(name string) (bitSignal, bool) {
+	for _, sig := range m.Signals {
+		if sig.Name == name {
+			return sig, true
+		}
+	}
+	return bitSignal{}, false
+}
+
+// rawValue converts a physical value to the signal's raw bits, checking
+// that it fits.
+func (s bitSignal) rawValue(value float64) (uint64, error) {
+	factor := s.Factor
+	if factor == 0 {
+		factor = 1
+	}
+	raw := math.Round((value - s.Offset) / factor)
+	lo, hi := 0.0, math.Pow(2, float64(s.Length))-1
+	if s.Signed {
+		lo, hi = -math.Pow(2, float64(s.Length-1)), math.Pow(2, float64(s.Length-1))-1
+	}
+	if raw < lo || raw > hi {
+		return 0, fmt.Errorf("value %g out of range", value)
+	}
+	return uint64(int64(raw)) & (1<<s.Length - 1), nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// crankDegreesPerCycle is one four-stroke cycle, two crank revolutions.
+const crankDegreesPerCycle = 720
+
+// injectorTimingDegrees is the injector timing as a crank angle: the
+// degrees the crank turns in the modelled timing at the current speed,
+// within one engine cycle.
+func injectorTimingDegrees(v vehicleModel) float64 {
+	degreesPerMs := float64(v.engineRPM) * 360 / 60000
+	return math.Mod(float64(v.injectorTiming)*degreesPerMs, crankDegreesPerCycle)
+}
+
+// applyInjectorTiming configures how InjectorTimingSensor is sent: in ms
+// or in crank degrees BTDC ("deg"), at resolution units per bit and in the
+// given byte order, if any.
+func applyInjectorTiming(unit string, resolution float64, order string) error {
+	msg, ok := MessageByName("InjectorTimingSensor")
+	if !ok {
+		return fmt.Errorf("no InjectorTimingSensor message to configure")
+	}
+	if resolution <= 0 {
+		return fmt.Errorf("invalid injector timing resolution %g", resolution)
+	}
+	if order != "" {
+		byteOrder, err := parseByteOrder(order)
+		if err != nil {
+			return err
+		}
+		msg.ByteOrder = byteOrder
+	}
+
+	switch unit {
+	case "ms":
+		msg.Value, msg.Unit = injectorTimingValue, "ms"
+	case "deg":
+		msg.Value, msg.Unit = injectorTimingDegrees, "°BTDC"
+	default:
+		return fmt.Errorf("invalid injector timing unit %q, expected ms or deg", unit)
+	}
+	msg.Factor = resolution
+	msg.Precision = max(0, int(math.Ceil(-math.Log10(resolution))))
+	updateMessage(msg)
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// ISO-TP (ISO 15765-2) frame types, the high nibble of the first byte.
+const (
+	isoTPSingleFrame      = 0x0
+	isoTPFirstFrame       = 0x1
+	isoTPConsecutiveFrame = 0x2
+	isoTPFlowControl      = 0x3
+)
+
+// Flow control status values.
+const (
+	isoTPContinueToSend = 0x0
+	isoTPWait           = 0x1
+	isoTPOverflow       = 0x2
+)
+
+// isoTPFlowTimeout is how long the sender waits for the tester's flow
+// control frame (N_Bs).
+const isoTPFlowTimeout = time.Second
+
+// maxISOTPLength is the longest payload with a 12-bit first frame length.
+const maxISOTPLength = 4095
+
+// send transmits a response payload, as a single frame when it fits and
+// otherwise as a first frame followed by consecutive frames paced by the
+// tester's flow control. The caller must hold d.mu.
+func (d *diagResponder) send(ctx context.Context, payload []byte) error {
+	if len(payload) <= 7 {
+		frame := can.Frame{ID: diagResponseID, Length: 8}
+		frame.Data[0] = byte(len(payload))
+		copy(frame.Data[1:], payload)
+		return d.tx.transmit(ctx, frame)
+	}
+	if len(payload) > maxISOTPLength {
+		return fmt.Errorf("response of %d bytes exceeds ISO-TP's %d", len(payload), maxISOTPLength)
+	}
+
+	// Discard flow control left over from an abandoned transfer
+	for len(d.flowControl) > 0 {
+		<-d.flowControl
+	}
+
+	first := can.Frame{ID: diagResponseID, Length: 8}
+	first.Data[0] = isoTPFirstFrame<<4 | byte(len(payload)>>8)
+	first.Data[1] = byte(len(payload))
+	copy(first.Data[2:], payload)
+	if err := d.tx.transmit(ctx, first); err != nil {
+		return err
+	}
+
+	rest := payload[6:]
+	for seq := byte(1); len(rest) > 0; {
+		blockSize, separation, err := d.awaitFlowControl(ctx)
+		if err != nil {
+			return err
+		}
+		for sent := 0; len(rest) > 0 && (blockSize == 0 || sent < blockSize); sent++ {
+			if seq > 1 || sent > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(separation):
+				}
+			}
+			frame := can.Frame{ID: diagResponseID, Length: 8}
+			frame.Data[0] = isoTPConsecutiveFrame<<4 | seq&0x0F
+			n := copy(frame.Data[1:], rest)
+			rest = rest[n:]
+			seq++
+			if err := d.tx.transmit(ctx, frame); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// awaitFlowControl waits for a clear-to-send flow control frame and
+// returns its block size and separation time.
+func (d *diagResponder) awaitFlowControl(ctx context.Context) (int, time.Duration, error) {
+	timeout := time.NewTimer(isoTPFlowTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, 0, ctx.Err()
+		case <-timeout.C:
+			return 0, 0, errors.New("timed out waiting for ISO-TP flow control")
+		case fc := <-d.flowControl:
+			switch fc.Data[0] & 0x0F {
+			case isoTPContinueToSend:
+				return int(fc.Data[1]), isoTPSeparation(fc.Data[2]), nil
+			case isoTPWait:
+				timeout.Reset(isoTPFlowTimeout)
+			case isoTPOverflow:
+				return 0, 0, errors.New("tester reported ISO-TP buffer overflow")
+			}
+		}
+	}
+}
+
+// isoTPSeparation decodes a flow control STmin byte. Reserved values are
+// treated as the maximum of 127 ms.
+func isoTPSeparation(stMin byte) time.Duration {
+	switch {
+	case stMin <= 0x7F:
+		return time.Duration(stMin) * time.Millisecond
+	case stMin >= 0xF1 && stMin <= 0xF9:
+		return time.Duration(stMin-0xF0) * 100 * time.Microsecond
+	}
+	return 127 * time.Millisecond
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"go.einride.tech/can"
+)
+
+// j1939SourceAddress is the address the simulated engine ECU transmits from.
+const j1939SourceAddress = 0x00
+
+// j1939ID is a 29-bit CAN ID split into its J1939 fields.
+type j1939ID struct {
+	Priority      uint8
+	PGN           uint32
+	Destination   uint8 // Only meaningful for PDU1 (PF < 240) PGNs
+	SourceAddress uint8
+}
+
+// parseJ1939ID splits an extended CAN ID. For PDU1 PGNs the PS byte is a
+// destination address and is not part of the PGN.
+func parseJ1939ID(id uint32) j1939ID {
+	j := j1939ID{
+		Priority:      uint8(id>>26) & 0x7,
+		PGN:           (id >> 8) & 0x3FFFF,
+		SourceAddress: uint8(id),
+	}
+	if pf := uint8(j.PGN >> 8); pf < 240 {
+		j.Destination = uint8(j.PGN)
+		j.PGN &^= 0xFF
+	}
+	return j
+}
+
+// canID assembles the 29-bit CAN ID.
+func (j j1939ID) canID() uint32 {
+	id := uint32(j.Priority&0x7)<<26 | j.PGN<<8 | uint32(j.SourceAddress)
+	if pf := uint8(j.PGN >> 8); pf < 240 {
+		id |= uint32(j.Destination) << 8
+	}
+	return id
+}
+
+// j1939Message is a message defined by its PGN instead of a fixed CAN ID.
+type j1939Message struct {
+	PGN      uint32
+	Name     string
+	Priority uint8
+	Decode   func(data []byte) string
+	Encode   func(v vehicleModel) [8]byte
+}
+
+// j1939Messages are the supported standard PGNs, keyed by PGN.
+var j1939Messages = map[uint32]j1939Message{
+	0xF004: {PGN: 0xF004, Name: "EEC1", Priority: 3, Decode: decodeEEC1, Encode: encodeEEC1},
+	0xFEEE: {PGN: 0xFEEE, Name: "ET1", Priority: 6, Decode: decodeET1, Encode: encodeET1},
+}
+
+// j1939NotAvailable fills payload bytes a message does not provide.
+var j1939NotAvailable = [8]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+// decodeEEC1 reads engine speed from Electronic Engine Controller 1:
+// bytes 4-5, little endian, 0.125 rpm/bit.
+func decodeEEC1(data []byte) string {
+	raw := binary.LittleEndian.Uint16(data[3:5])
+	if raw >= 0xFB00 {
+		return "Engine Speed: not available"
+	}
+	return fmt.Sprintf("Engine Speed: %.3f rpm", float64(raw)*0.125)
+}
+
+func encodeEEC1(v vehicleModel) [8]byte {
+	data := j1939NotAvailable
+	binary.LittleEndian.PutUint16(data[3:5], uint16(v.engineRPM*8))
+	data[5] = j1939SourceAddress
+	return data
+}
+
+// decodeET1 reads coolant temperature from Engine Temperature 1: byte 1,
+// 1 °C/bit with a -40 °C offset.
+func decodeET1(data []byte) string {
+	if data[0] >= 0xFB {
+		return "Engine Coolant Temperature: not available"
+	}
+	return fmt.Sprintf("Engine Coolant Temperature: %d °C", int(data[0])-40)
+}
+
+func encodeET1(v vehicleModel) [8]byte {
+	data := j1939NotAvailable
+	data[0] = byte(min(max(v.engineTemp+40, 0), 0xFA))
+	return data
+}
+
+// j1939Frame builds the frame for a J1939 message from the model.
+func j1939Frame(msg j1939Message, v vehicleModel) can.Frame {
+	id := j1939ID{Priority: msg.Priority, PGN: msg.PGN, SourceAddress: j1939SourceAddress}
+	return can.Frame{ID: id.canID(), IsExtended: true, Length: 8, Data: msg.Encode(v)}
+}
+
+// decodeJ1939 looks up an extended frame by PGN.
+func decodeJ1939(frame can.Frame) (j1939ID, j1939Message, bool) {
+	id := parseJ1939ID(frame.ID)
+	msg, ok := j1939Messages[id.PGN]
+	return id, msg, ok
+}
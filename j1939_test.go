@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestParseJ1939ID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   uint32
+		want j1939ID
+	}{
+		{
+			name: "PDU2 EEC1",
+			id:   0x0CF00400,
+			want: j1939ID{Priority: 3, PGN: 0xF004, SourceAddress: 0x00},
+		},
+		{
+			name: "PDU2 ET1",
+			id:   0x18FEEE00,
+			want: j1939ID{Priority: 6, PGN: 0xFEEE, SourceAddress: 0x00},
+		},
+		{
+			name: "PDU1 request with destination",
+			id:   0x18EA17F9,
+			want: j1939ID{Priority: 6, PGN: 0xEA00, Destination: 0x17, SourceAddress: 0xF9},
+		},
+		{
+			name: "PDU1 with data page",
+			id:   0x1DEFFF03,
+			want: j1939ID{Priority: 7, PGN: 0x1EF00, Destination: 0xFF, SourceAddress: 0x03},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseJ1939ID(tt.id)
+			if got != tt.want {
+				t.Errorf("parseJ1939ID(0x%08x) = %+v, want %+v", tt.id, got, tt.want)
+			}
+			if id := got.canID(); id != tt.id {
+				t.Errorf("canID() = 0x%08x, want 0x%08x", id, tt.id)
+			}
+		})
+	}
+}
+
+func TestJ1939FrameRoundTrip(t *testing.T) {
+	v := vehicleModel{engineRPM: 1500, engineTemp: 85}
+	tests := []struct {
+		pgn  uint32
+		want string
+	}{
+		{pgn: 0xF004, want: "Engine Speed: 1500.000 rpm"},
+		{pgn: 0xFEEE, want: "Engine Coolant Temperature: 85 °C"},
+	}
+	for _, tt := range tests {
+		frame := j1939Frame(j1939Messages[tt.pgn], v)
+		id, msg, ok := decodeJ1939(frame)
+		if !ok || id.PGN != tt.pgn {
+			t.Fatalf("decodeJ1939(0x%08x) = PGN 0x%x, %t, want PGN 0x%x", frame.ID, id.PGN, ok, tt.pgn)
+		}
+		if got := msg.Decode(frame.Data[:]); got != tt.want {
+			t.Errorf("PGN 0x%x decodes as %q, want %q", tt.pgn, got, tt.want)
+		}
+	}
+}
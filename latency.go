@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the histogram buckets, growing
+// geometrically from 100µs to about 13s.
+var latencyBuckets = func() []time.Duration {
+	var bounds []time.Duration
+	for d := 100 * time.Microsecond; d < 15*time.Second; d = d * 3 / 2 {
+		bounds = append(bounds, d)
+	}
+	return bounds
+}()
+
+// latencyHistogram accumulates request/response latencies. Min, max and
+// average are exact; percentiles are resolved to a bucket bound.
+type latencyHistogram struct {
+	count  uint64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+	counts []uint64 // Per latencyBuckets entry, plus one overflow bucket
+}
+
+// latencySummary is the reported form of a latencyHistogram.
+type latencySummary struct {
+	Count uint64  `json:"count"`
+	MinMs float64 `json:"min_ms"`
+	AvgMs float64 `json:"avg_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	MaxMs float64 `json:"max_ms"`
+}
+
+// add records one latency sample.
+func (h *latencyHistogram) add(d time.Duration) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets)+1)
+	}
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+
+	i := len(latencyBuckets)
+	for j, bound := range latencyBuckets {
+		if d <= bound {
+			i = j
+			break
+		}
+	}
+	h.counts[i]++
+}
+
+// percentile returns the bucket bound below which fraction p of the samples
+// fall, capped at the observed maximum.
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	target := uint64(math.Ceil(p * float64(h.count)))
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= target && i < len(latencyBuckets) {
+			return min(latencyBuckets[i], h.max)
+		}
+	}
+	return h.max
+}
+
+// summary reports the histogram, or nil when it holds no samples.
+func (h *latencyHistogram) summary() *latencySummary {
+	if h.count == 0 {
+		return nil
+	}
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return &latencySummary{
+		Count: h.count,
+		MinMs: ms(h.min),
+		AvgMs: ms(h.sum / time.Duration(h.count)),
+		P95Ms: ms(h.percentile(0.95)),
+		MaxMs: ms(h.max),
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// frontLightSignals are the lamp bits of the FrontLight message. Low beam
+// is bit 0, so the original on/off command (data[0] == 1) still switches
+// the headlights.
+var frontLightSignals = []bitSignal{
+	{Name: "LowBeam", StartBit: 0, Length: 1},
+	{Name: "HighBeam", StartBit: 1, Length: 1},
+	{Name: "Fog", StartBit: 2, Length: 1},
+	{Name: "DRL", StartBit: 3, Length: 1},
+}
+
+// frontLightSignal looks up a lamp by name, ignoring case.
+func frontLightSignal(name string) (bitSignal, bool) {
+	for _, sig := range frontLightSignals {
+		if strings.EqualFold(sig.Name, name) {
+			return sig, true
+		}
+	}
+	return bitSignal{}, false
+}
+
+// decodeFrontLight prints each lamp state carried by the FrontLight message.
+func decodeFrontLight(data []byte) string {
+	states := make([]string, len(frontLightSignals))
+	for i, sig := range frontLightSignals {
+		state := "OFF"
+		if sig.extract(data) == 1 {
+			state = "ON"
+		}
+		states[i] = sig.Name + " " + state
+	}
+	return "Front Light: " + strings.Join(states, ", ")
+}
+
+// setFrontLights applies a received FrontLight command to the model.
+func setFrontLights(data []byte) {
+	simulationMux.Lock()
+	defer simulationMux.Unlock()
+	for _, sig := range frontLightSignals {
+		sig.insert(&vehicle.frontLights, sig.extract(data))
+	}
+}
+
+// setLamp switches a single lamp, leaving the others as they are.
+func setLamp(name string, on bool) error {
+	sig, ok := frontLightSignal(name)
+	if !ok {
+		return fmt.Errorf("unknown lamp %q", name)
+	}
+	var value uint64
+	if on {
+		value = 1
+	}
+	simulationMux.Lock()
+	defer simulationMux.Unlock()
+	sig.insert(&vehicle.frontLights, value)
+	return nil
+}
+
+// lampStates returns each lamp's state in the model, keyed by name.
+func (v vehicleModel) lampStates() map[string]bool {
+	states := make(map[string]bool, len(frontLightSignals))
+	for _, sig := range frontLightSignals {
+		states[sig.Name] = sig.extract(v.frontLights[:]) == 1
+	}
+	return states
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Limp-home mode protects a degraded engine: speed is capped at
+// limpHomeRPM, set with -limp-rpm, and follows the throttle at
+// limpHomeResponse of its normal rate.
+const limpHomeResponse = 0.25
+
+var limpHomeRPM = 3000
+
+// limpHomeFaultCode is the fault reported while in limp-home mode.
+const limpHomeFaultCode = 0x10
+
+// warningLampSignals are the lamp bits of the WarningLamps message.
+var warningLampSignals = []bitSignal{
+	{Name: "MIL", StartBit: 0, Length: 1, Comment: "Check engine lamp, lit while any fault is active"},
+	{Name: "LimpHome", StartBit: 1, Length: 1, Comment: "Reduced power warning"},
+}
+
+// setLimpHome switches limp-home mode, from the 0x105 LimpHome control
+// frame.
+func setLimpHome(on bool) {
+	simulationMux.Lock()
+	changed := vehicle.limpHome != on
+	vehicle.limpHome = on
+	simulationMux.Unlock()
+
+	if !changed {
+		return
+	}
+	if on {
+		log.Printf("Limp-home mode on: engine speed capped at %d rpm", limpHomeRPM)
+	} else {
+		log.Println("Limp-home mode off")
+	}
+}
+
+func decodeLimpHome(data []byte) string {
+	if data[0] == 1 {
+		return "Limp Home: ON"
+	}
+	return "Limp Home: OFF"
+}
+
+func encodeWarningLamps(v vehicleModel) [8]byte {
+	var data [8]byte
+	if v.limpHome || len(v.activeFaults) > 0 {
+		warningLampSignals[0].insert(&data, 1)
+	}
+	if v.limpHome {
+		warningLampSignals[1].insert(&data, 1)
+	}
+	return data
+}
+
+func decodeWarningLamps(data []byte) string {
+	states := make([]string, len(warningLampSignals))
+	for i, sig := range warningLampSignals {
+		state := "OFF"
+		if sig.extract(data) == 1 {
+			state = "ON"
+		}
+		states[i] = sig.Name + " " + state
+	}
+	return fmt.Sprintf("Warning Lamps: %s", strings.Join(states, ", "))
+}
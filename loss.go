@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// lossRate is the percentage of transmitted frames randomly dropped to
+// simulate a lossy bus, and lossRates the per-message overrides. Both are
+// set once from -loss before the simulator starts.
+var (
+	lossRate  float64
+	lossRates = make(map[string]float64)
+)
+
+// parseLossRate parses a drop percentage between 0 and 100.
+func parseLossRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || rate < 0 || rate > 100 {
+		return 0, fmt.Errorf("invalid loss rate %q, expected a percentage between 0 and 100", s)
+	}
+	return rate, nil
+}
+
+// applyLossRates sets the global drop percentage and per-message
+// overrides from a -loss value like "2,EngineRPM=20".
+func applyLossRates(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		name, value, override := strings.Cut(strings.TrimSpace(item), "=")
+		if !override {
+			rate, err := parseLossRate(name)
+			if err != nil {
+				return err
+			}
+			lossRate = rate
+			continue
+		}
+		if _, ok := MessageByName(name); !ok {
+			return fmt.Errorf("unknown message %q in loss rates", name)
+		}
+		rate, err := parseLossRate(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		lossRates[name] = rate
+	}
+	return nil
+}
+
+// lost reports whether a frame of the named message is randomly dropped.
+func lost(name string) bool {
+	rate, ok := lossRates[name]
+	if !ok {
+		rate = lossRate
+	}
+	return rate > 0 && rand.Float64()*100 < rate
+}
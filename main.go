@@ -2,11 +2,19 @@ package main
 
 import (
 	"context"
-	"encoding/hex"
+	"flag"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"go.einride.tech/can"
@@ -14,33 +22,94 @@ import (
 )
 
 // CANMessage represents each command in the DBC database.
+//
+// Messages with an Encode or Value function are transmitted by the simulator
+// from the current vehicle model. RequiresEngine restricts transmission to
+// while the engine is on; the others broadcast from startup.
+//
+// Messages with a Format are decoded generically from their physical value
+// instead of through Decode; see physical.go.
 type CANMessage struct {
-	ID      uint32
-	Name    string
-	DataLen uint8
-	Decode  func(data []byte) string
+	ID              uint32
+	Name            string
+	DataLen         uint8
+	Decode          func(data []byte) string
+	Encode          func(v vehicleModel) [8]byte
+	RequiresEngine  bool
+	Checksum        Checksum        // Integrity byte carried in the last data byte
+	CounterByte     uint8           // Data byte holding the rolling counter
+	CounterBits     uint8           // Width of the rolling counter, 0 for none
+	Interval        time.Duration   // Transmit interval, defaultInterval if zero
+	TxType          txType          // When the message is transmitted, see scheduler.go
+	MinIgnition     IgnitionState   // Lowest ignition state the message is sent in
+	ChangeThreshold float64         // Physical value change that fires an event
+	Signals         []bitSignal     // Bit-positioned fields of the payload
+	Comment         string          // Description, from the DBC CM_ BO_ entry
+	Template        payloadTemplate // Payload built from placeholders, see template.go
+
+	Value     func(v vehicleModel) float64
+	ValueLen  uint8
+	ByteOrder byteOrder // Of the raw value and hand-written fields, see byteorder.go
+	Factor    float64
+	Offset    float64
+	Format    string
+	Precision int
+	Unit      string // Physical unit, appended to the formatted value
 }
 
-// Define the DBC-like structure with commands and required data length.
-var CAN_DBC = map[uint32]CANMessage{
-	0x100: {ID: 0x100, Name: "EngineOnOff", DataLen: 8, Decode: decodeEngineOnOff},
-	0x101: {ID: 0x101, Name: "FrontLight", DataLen: 8, Decode: decodeFrontLight},
-	0x200: {ID: 0x200, Name: "EngineTempSensor", DataLen: 8, Decode: decodeEngineTemp},
-	0x201: {ID: 0x201, Name: "InjectorTimingSensor", DataLen: 8, Decode: decodeInjectorTiming},
-	0x202: {ID: 0x202, Name: "OxygenSensor", DataLen: 8, Decode: decodeOxygenSensor},
-	0x203: {ID: 0x203, Name: "FuelTankLevel", DataLen: 8, Decode: decodeFuelTankLevel},
-	0x204: {ID: 0x204, Name: "ThrottlePosition", DataLen: 8, Decode: decodeThrottlePosition},
-	0x205: {ID: 0x205, Name: "EngineRPM", DataLen: 8, Decode: decodeEngineRPM},
+// builtinMessages defines the DBC-like structure with commands and required data length.
+var builtinMessages = []CANMessage{
+	{ID: 0x100, Name: "EngineOnOff", DataLen: 8, Decode: decodeEngineOnOff},
+	{ID: 0x101, Name: "FrontLight", DataLen: 8, Decode: decodeFrontLight, Signals: frontLightSignals},
+	{ID: 0x102, Name: "ErrorInject", DataLen: 8, Decode: decodeErrorInject},
+	{ID: 0x103, Name: "DiagDelay", DataLen: 8, Decode: decodeDiagDelay},
+	{ID: 0x104, Name: "ResetState", DataLen: 8, Decode: decodeResetState},
+	{ID: 0x105, Name: "LimpHome", DataLen: 8, Decode: decodeLimpHome},
+	{ID: 0x106, Name: "Ignition", DataLen: 8, Decode: decodeIgnition},
+	{ID: 0x107, Name: "StatusCommand", DataLen: 8, Decode: decodeStatusCommand},
+	{ID: 0x200, Name: "EngineTempSensor", DataLen: 8, Value: engineTempValue, ValueLen: 2, Format: "Engine Temperature: {value}", Unit: "°C", RequiresEngine: true},
+	{ID: 0x201, Name: "InjectorTimingSensor", DataLen: 8, Value: injectorTimingValue, ValueLen: 2, Format: "Injector Timing: {value}", Unit: "ms", RequiresEngine: true},
+	{ID: 0x202, Name: "OxygenSensor", DataLen: 8, Value: oxygenSensorValue, ValueLen: 1, Format: "Oxygen Sensor: {value}", Unit: "%", RequiresEngine: true},
+	{ID: 0x203, Name: "FuelTankLevel", DataLen: 8, Value: fuelTankLevelValue, ValueLen: 1, Format: "Fuel Tank Level: {value}", Unit: "%", RequiresEngine: true},
+	{ID: 0x204, Name: "ThrottlePosition", DataLen: 8, Value: throttlePositionValue, ValueLen: 1, Decode: decodeThrottlePosition, Encode: encodeThrottlePosition, Signals: throttlePositionSignals, Unit: "%", RequiresEngine: true, Checksum: checksumCRC8H2F, CounterByte: 6, CounterBits: 4},
+	{ID: 0x205, Name: "EngineRPM", DataLen: 8, Value: engineRPMValue, ValueLen: 2, Format: "Engine RPM: {value}", Unit: "rpm", RequiresEngine: true, Checksum: checksumCRC8SAEJ1850, CounterByte: 6, CounterBits: 4},
+	{ID: 0x206, Name: "MassAirFlow", DataLen: 8, Decode: decodeFloat32("MassAirFlow", "Mass Air Flow", "g/s"), Encode: encodeMassAirFlow, Unit: "g/s", RequiresEngine: true},
+	{ID: 0x207, Name: "EngineHours", DataLen: 8, Decode: decodeEngineHours, Encode: encodeEngineHours, Unit: "h", RequiresEngine: true},
+	{ID: 0x208, Name: "AmbientTemp", DataLen: 8, Decode: decodeAmbientTemp, Encode: encodeAmbientTemp},
+	{ID: 0x209, Name: "BatteryVoltage", DataLen: 8, Decode: decodeBatteryVoltage, Encode: encodeBatteryVoltage},
+	{ID: 0x20A, Name: "KeyPosition", DataLen: 8, Decode: decodeKeyPosition, Encode: encodeKeyPosition},
+	{ID: 0x20B, Name: "SoftwareVersion", DataLen: 8, Decode: decodeSoftwareVersion, Encode: encodeSoftwareVersion},
+	{ID: 0x20C, Name: "WarningLamps", DataLen: 8, Decode: decodeWarningLamps, Encode: encodeWarningLamps, Signals: warningLampSignals},
+	{ID: 0x20D, Name: "VehicleStatus", DataLen: 8, Decode: decodeVehicleStatus, Encode: encodeVehicleStatus, Signals: vehicleStatusSignals},
+	{ID: 0x20E, Name: "EngineAcceleration", DataLen: 8, Decode: decodeEngineAcceleration, Encode: encodeEngineAcceleration, Signals: engineAccelerationSignals, Unit: "rpm/s", RequiresEngine: true},
+	{ID: 0x20F, Name: "EngineStatus", DataLen: 8, Decode: decodeEngineStatus, Encode: encodeEngineStatus, Interval: engineStatusInterval},
+	{ID: 0x210, Name: "PowertrainMode", DataLen: 8, Decode: decodeNibbles("Powertrain Mode", 0, "Ignition", "Mode"), Encode: encodePowertrainMode, Signals: powertrainModeSignals},
+	{ID: 0x2F0, Name: "PlausibilityFault", DataLen: 8, Decode: decodePlausibilityFault},
+	{ID: 0x2F1, Name: "ActiveFaults", DataLen: 8, Decode: decodeActiveFaults},
+	{ID: 0x2F2, Name: "SimulatorMetadata", DataLen: 8, Decode: decodeSimulatorMetadata},
 }
 
+// CAN_DBC indexes the active messages by ID. It is built by loadDBC and
+// installed with useDBC before the simulator starts.
+var CAN_DBC map[uint32]CANMessage
+
 // Global variables to track engine state and control simulation.
 var (
-	engineOn      bool
-	simulationMux sync.Mutex
+	engineOn          bool
+	sensorLoopRunning bool // simulateSensors is active, possibly coasting down
+	simulationMux     sync.Mutex
+	j1939Enabled      bool // Also transmit and decode J1939 PGNs
+	strictMode        bool // Treat frames with IDs missing from the DBC as errors
+	padFrames         bool // Transmit every message with an 8-byte DLC
+
+	// sensorLoops counts running simulateSensors goroutines. More than one
+	// would transmit every sensor frame twice, so a second one exits.
+	sensorLoops atomic.Int32
 )
 
 func init() {
-	rand.Seed(time.Now().UnixNano())
+	simulationSeed = time.Now().UnixNano()
+	rand.Seed(simulationSeed)
 }
 
 // Helper functions to generate fluctuating sensor values within specific ranges.
@@ -48,6 +117,10 @@ func fluctuate(min, max int) int {
 	return min + rand.Intn(max-min+1)
 }
 
+func fluctuateFloat(min, max float64) float64 {
+	return min + rand.Float64()*(max-min)
+}
+
 // Decoding functions for each command.
 func decodeEngineOnOff(data []byte) string {
 	if data[0] == 1 {
@@ -56,131 +129,818 @@ func decodeEngineOnOff(data []byte) string {
 	return "Engine OFF"
 }
 
-func decodeFrontLight(data []byte) string {
-	if data[0] == 1 {
-		return "Front Light ON"
+func decodeResetState(data []byte) string {
+	return "Reset State"
+}
+
+// decodeEngineHours reads the engine run-time counter, sent in seconds.
+func decodeEngineHours(data []byte) string {
+	seconds := byteOrderOf("EngineHours").Uint32(data[:4])
+	return fmt.Sprintf("Engine Hours: %.1f h", float64(seconds)/3600)
+}
+
+func decodeAmbientTemp(data []byte) string {
+	return fmt.Sprintf("Ambient Temperature: %d °C", int8(data[0]))
+}
+
+func decodeBatteryVoltage(data []byte) string {
+	centivolts := byteOrderOf("BatteryVoltage").Uint16(data[:2])
+	return fmt.Sprintf("Battery Voltage: %.2f V", float64(centivolts)/100)
+}
+
+func decodeKeyPosition(data []byte) string {
+	return fmt.Sprintf("Key Position: %s", IgnitionState(data[0]))
+}
+
+// decodeFloat32 returns a decoder for signals sent as a 32-bit IEEE-754
+// float in the first four data bytes, in the byte order of message name.
+func decodeFloat32(name, label, unit string) func(data []byte) string {
+	return func(data []byte) string {
+		value := math.Float32frombits(byteOrderOf(name).Uint32(data[:4]))
+		return fmt.Sprintf("%s: %.3f %s", label, value, unit)
 	}
-	return "Front Light OFF"
 }
 
-func decodeEngineTemp(data []byte) string {
-	temp := int(data[0])<<8 | int(data[1])
-	return fmt.Sprintf("Engine Temperature: %d °C", temp)
+// Encoding functions build each simulated message from the vehicle model.
+func encodeMassAirFlow(v vehicleModel) [8]byte {
+	var data [8]byte
+	byteOrderOf("MassAirFlow").PutUint32(data[:4], math.Float32bits(v.massAirFlow))
+	return data
 }
 
-func decodeInjectorTiming(data []byte) string {
-	timing := int(data[0])<<8 | int(data[1])
-	return fmt.Sprintf("Injector Timing: %d ms", timing)
+func encodeEngineHours(v vehicleModel) [8]byte {
+	var data [8]byte
+	byteOrderOf("EngineHours").PutUint32(data[:4], uint32(v.engineHours/time.Second))
+	return data
 }
 
-func decodeOxygenSensor(data []byte) string {
-	return fmt.Sprintf("Oxygen Sensor: %d%%", data[0])
+func encodeAmbientTemp(v vehicleModel) [8]byte {
+	return [8]byte{byte(int8(v.ambientTemp))}
 }
 
-func decodeFuelTankLevel(data []byte) string {
-	return fmt.Sprintf("Fuel Tank Level: %d%%", data[0])
+func encodeBatteryVoltage(v vehicleModel) [8]byte {
+	var data [8]byte
+	byteOrderOf("BatteryVoltage").PutUint16(data[:2], uint16(math.Round(float64(v.batteryVoltage)*100)))
+	return data
 }
 
-func decodeThrottlePosition(data []byte) string {
-	return fmt.Sprintf("Throttle Position: %d%%", data[0])
+func encodeKeyPosition(v vehicleModel) [8]byte {
+	return [8]byte{byte(v.ignition)}
+}
+
+// simulatedMessages returns the transmitted messages in ID order, either
+// the engine-gated ones or the always-on ones.
+func simulatedMessages(requiresEngine bool) []CANMessage {
+	var msgs []CANMessage
+	for _, msg := range CAN_DBC {
+		if (msg.Encode != nil || msg.Value != nil) && msg.RequiresEngine == requiresEngine {
+			msgs = append(msgs, msg)
+		}
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].ID < msgs[j].ID })
+	return msgs
+}
+
+// frame builds the frame transmitting data for m. Its DLC is the
+// message's DataLen, or a full 8 bytes with -pad.
+func (m CANMessage) frame(data [8]byte) can.Frame {
+	length := m.DataLen
+	if padFrames || length == 0 || length > 8 {
+		length = 8
+	}
+	return can.Frame{ID: m.ID, Length: length, Data: data}
+}
+
+// transmitMessages sends one frame per message built from the given state.
+func transmitMessages(ctx context.Context, tx *busTransmitter, msgs []CANMessage, state vehicleModel, filter *signalFilter, counters txCounters) {
+	now := time.Now()
+	for _, msg := range msgs {
+		if droppedOut(msg.Name, now) || state.ignition < msg.MinIgnition {
+			continue
+		}
+		var data [8]byte
+		if msg.Template != nil {
+			data = msg.Template.render(state, counters.nextByte(msg.ID))
+		} else {
+			data = msg.encode(state, filter)
+			msg.applyCounter(&data, counters.next(msg))
+			msg.applyChecksum(&data)
+		}
+		// Dropped after the counter has advanced, as a frame lost on the
+		// wire would be
+		if lost(msg.Name) {
+			stats.recordDrop(msg.ID)
+			continue
+		}
+		tx.transmit(ctx, msg.frame(data))
+	}
 }
 
-func decodeEngineRPM(data []byte) string {
-	rpm := int(data[0])<<8 | int(data[1])
-	return fmt.Sprintf("Engine RPM: %d", rpm)
+// broadcastAlwaysOn transmits the messages that do not depend on the engine
+// running, from startup until ctx is cancelled.
+func broadcastAlwaysOn(ctx context.Context) {
+	tx, err := dialTransmitter(ctx, "vcan0")
+	if err != nil {
+		log.Fatalf("always-on broadcast: %v", err)
+	}
+	defer tx.Close()
+	tx.onTransmit = onTransmit
+
+	msgs := simulatedMessages(false)
+	filter := newSignalFilter()
+	counters := make(txCounters)
+
+	sched := newTxScheduler(msgs, time.Now())
+	for {
+		due, ok := sched.next(ctx)
+		if !ok {
+			return
+		}
+
+		simulationMux.Lock()
+		vehicle.tickElectrical(engineOn)
+		state := vehicle
+		state.applyOverrides()
+		simulationMux.Unlock()
+
+		due = sched.withEvents(due, state)
+		transmitMessages(ctx, tx, due, state, filter, counters)
+	}
 }
 
 // simulateSensors continuously sends fluctuating sensor data to the CAN bus if the engine is on.
+// Only messages with RequiresEngine set are sent here; see broadcastAlwaysOn.
 func simulateSensors(ctx context.Context) {
+	if n := sensorLoops.Add(1); n > 1 {
+		sensorLoops.Add(-1)
+		log.Printf("Warning: sensor simulation already running (%d goroutines), not starting another", n-1)
+		return
+	}
+
 	log.Println("Opening TX CAN interface. . .")
 
-	conn, err := socketcan.DialContext(ctx, "can", "vcan0")
+	tx, err := dialTransmitter(ctx, "vcan0")
 	if err != nil {
-		log.Fatalf("failed to connect to vcan0 for sensor simulation: %v", err)
+		log.Fatalf("sensor simulation: %v", err)
 	}
-	defer conn.Close()
+	defer tx.Close()
+	tx.onTransmit = onTransmit
 
 	log.Println("Prepare for transmitting message through TX CAN interface. . .")
-	tx := socketcan.NewTransmitter(conn)
 
+	msgs := simulatedMessages(true)
+	filter := newSignalFilter()
+	counters := make(txCounters)
+
+	sched := newTxScheduler(msgs, time.Now())
+	lastTick := time.Now()
+	var lastFaultCheck time.Time
+	var stall stallDetector
 	for {
-		simulationMux.Lock()
-		if !engineOn {
-			simulationMux.Unlock()
+		due, ok := sched.next(ctx)
+		if !ok {
+			sensorLoops.Add(-1)
 			return
 		}
-		simulationMux.Unlock()
 
-		// Generate fluctuating sensor values within defined ranges
-		engineTemp := fluctuate(80, 100)      // Engine Temp: 80 - 100 °C
-		injectorTiming := fluctuate(60, 90)   // Injector Timing: 60 - 90 ms
-		oxygenSensor := fluctuate(90, 100)    // Oxygen Sensor: 90 - 100%
-		fuelTankLevel := fluctuate(60, 80)    // Fuel Tank Level: 60 - 80%
-		throttlePosition := fluctuate(40, 60) // Throttle Position: 40 - 60%
-		engineRPM := fluctuate(2500, 3000)    // Engine RPM: 2500 - 3000
+		now := time.Now()
+		state, running, stopped := advanceVehicle(now, &lastTick)
+		if stopped {
+			return
+		}
+		if running && stall.check(state, now) {
+			stallEngine(state.engineRPM)
+		}
+
+		// Send fluctuating sensor data frames to the CAN bus, on their
+		// cadence or on a change
+		due = sched.withEvents(due, state)
+		transmitMessages(ctx, tx, due, state, filter, counters)
+
+		// Spinning down with the throttle closed is expected, not a fault,
+		// and so is the gauge sweep
+		if !running || state.sweeping() || now.Sub(lastFaultCheck) < defaultInterval {
+			continue
+		}
+		lastFaultCheck = now
 
-		// Send fluctuating sensor data frames to the CAN bus
-		tx.TransmitFrame(context.Background(), can.Frame{ID: 0x200, Length: 8, Data: [8]byte{byte(engineTemp >> 8), byte(engineTemp & 0xFF)}})
-		tx.TransmitFrame(context.Background(), can.Frame{ID: 0x201, Length: 8, Data: [8]byte{byte(injectorTiming >> 8), byte(injectorTiming & 0xFF)}})
-		tx.TransmitFrame(context.Background(), can.Frame{ID: 0x202, Length: 8, Data: [8]byte{byte(oxygenSensor)}})
-		tx.TransmitFrame(context.Background(), can.Frame{ID: 0x203, Length: 8, Data: [8]byte{byte(fuelTankLevel)}})
-		tx.TransmitFrame(context.Background(), can.Frame{ID: 0x204, Length: 8, Data: [8]byte{byte(throttlePosition)}})
-		tx.TransmitFrame(context.Background(), can.Frame{ID: 0x205, Length: 8, Data: [8]byte{byte(engineRPM >> 8), byte(engineRPM & 0xFF)}})
+		if j1939Enabled {
+			for _, msg := range j1939Messages {
+				tx.transmit(ctx, j1939Frame(msg, state))
+			}
+		}
+
+		// Report implausible signal combinations when they first appear, and
+		// broadcast the set of active faults for as long as any persist
+		var violated []uint8
+		for _, rule := range checkPlausibility(state) {
+			violated = append(violated, rule.FaultCode)
+			if !slices.Contains(state.activeFaults, rule.FaultCode) {
+				log.Printf("Plausibility fault 0x%02x (%s) detected", rule.FaultCode, rule.Name)
+				tx.transmit(ctx, can.Frame{ID: 0x2F0, Length: 8, Data: [8]byte{rule.FaultCode}})
+			}
+		}
+		if len(violated) > 0 {
+			tx.transmit(ctx, can.Frame{ID: 0x2F1, Length: 8, Data: encodeActiveFaults(violated)})
+		}
+		recordFaults(violated, now)
+	}
+}
+
+// advanceVehicle ticks the model if a tick is due and returns the state to
+// transmit and whether the engine is running. It reports stopped, having
+// left the loop count, once the engine is off and has spun down. The lock
+// is released by a defer so a panic in the model cannot leave it held.
+func advanceVehicle(now time.Time, lastTick *time.Time) (state vehicleModel, running, stopped bool) {
+	simulationMux.Lock()
+	defer simulationMux.Unlock()
+
+	if !engineOn && !vehicle.coasting() {
+		// Leave the count before the lock is released, so an engine
+		// start that follows at once is not refused as a duplicate
+		sensorLoops.Add(-1)
+		sensorLoopRunning = false
+		return state, false, true
+	}
+	if now.Sub(*lastTick) >= modelTickInterval {
+		vehicle.tick(modelDuration(now.Sub(*lastTick)), engineOn)
+		*lastTick = now
+	}
+	state = vehicle
+	state.applyOverrides()
+	return state, engineOn, false
+}
+
+// recordFaults stores the currently violated plausibility rules.
+func recordFaults(violated []uint8, now time.Time) {
+	simulationMux.Lock()
+	defer simulationMux.Unlock()
+	vehicle.activeFaults = violated
+	dtcs.record(violated, now)
+}
+
+// setEngineState switches the engine on or off, starting the sensor
+// simulation when the engine is turned on.
+func setEngineState(ctx context.Context, on bool) {
+	simulationMux.Lock()
+	defer simulationMux.Unlock()
+
+	// The engine command also turns the key, for senders that know
+	// nothing of the ignition
+	if on {
+		vehicle.ignition = max(vehicle.ignition, ignitionRun)
+	} else {
+		vehicle.ignition = ignitionOff
+	}
+	if on && !engineOn {
+		engineOn = true
+		vehicle.engineStarted(modelNow())
+		start := "Cold"
+		if vehicle.engineTemp >= operatingTemp {
+			start = "Warm"
+		}
+		log.Printf("%s start at %d °C", start, vehicle.engineTemp)
+		// Start sensor simulation, unless the previous run is still
+		// coasting down and will carry on
+		if !sensorLoopRunning {
+			sensorLoopRunning = true
+			go superviseSensors(ctx)
+		}
+	} else if !on && engineOn {
+		engineOn = false
+		vehicle.engineStopped(modelNow())
+	}
+}
+
+// resetVehicleState handles a ResetState command from the bus or the HTTP
+// API.
+func resetVehicleState() {
+	simulationMux.Lock()
+	vehicle.ResetState()
+	dtcs.reset()
+	simulationMux.Unlock()
+	log.Println("Vehicle state reset: engine hours, faults and DTCs cleared")
+}
+
+// handleFrame acts on any command a received frame carries and returns its
+// decoded text, or "" if it is unknown or fails validation.
+func handleFrame(ctx context.Context, frame can.Frame, counters rxCounters, responder *diagResponder) string {
+	// Remote frames poll a sensor and carry no command
+	if frame.IsRemote {
+		return handleRemoteFrame(ctx, frame)
+	}
+
+	// Handle engine on/off command. Only the first data byte is
+	// significant, so a minimal one-byte command frame is accepted.
+	if frame.ID == 0x100 {
+		if frame.Length < 1 {
+			warnFrame(frame, "Frame ID 0x%x ignored: engine command carries no data", frame.ID)
+			stats.recordDecodeError(frame.ID)
+			return ""
+		}
+		setEngineState(ctx, frame.Data[0] == 1)
+	}
+
+	// Handle front light command, one bit per lamp
+	if frame.ID == 0x101 && frame.Length >= 1 {
+		setFrontLights(frame.Data[:frame.Length])
+	}
+
+	// Handle status flag command: a mask of the flags to change, then
+	// their values
+	if frame.ID == 0x107 && frame.Length >= 2 {
+		setStatusFlags(frame.Data[0], frame.Data[1])
+	}
+
+	// Handle error frame injection command
+	if frame.ID == 0x102 && frame.Length >= 1 {
+		go injectErrorFrame(ctx, frame)
+	}
+
+	// Handle diagnostic response delay command
+	if frame.ID == 0x103 {
+		handleDiagDelayCommand(frame)
+	}
+
+	// Handle accumulated state reset command
+	if frame.ID == 0x104 {
+		resetVehicleState()
+	}
+
+	// Handle limp-home mode command
+	if frame.ID == 0x105 && frame.Length >= 1 {
+		setLimpHome(frame.Data[0] == 1)
+	}
+
+	// Handle ignition switch command
+	if frame.ID == 0x106 && frame.Length >= 1 {
+		if state := IgnitionState(frame.Data[0]); state <= ignitionCrank {
+			setIgnition(ctx, state)
+		} else {
+			warnFrame(frame, "Frame ID 0x%x ignored: unknown ignition state %d", frame.ID, frame.Data[0])
+		}
+	}
+
+	// Answer OBD-II/UDS requests
+	if isDiagRequest(frame) && frame.Length >= 1 {
+		go responder.respond(ctx, frame)
+	}
+
+	// Decode received J1939 messages by PGN
+	if j1939Enabled && frame.IsExtended {
+		if id, msg, ok := decodeJ1939(frame); ok {
+			if frame.Length < 8 {
+				warnFrame(frame, "Frame ID 0x%x (%s) ignored: DLC %d, expected 8", frame.ID, msg.Name, frame.Length)
+				stats.recordDecodeError(frame.ID)
+				return ""
+			}
+			return fmt.Sprintf("PGN %d (%s) SA 0x%02x	'%s'", id.PGN, msg.Name, id.SourceAddress, msg.Decode(frame.Data[:]))
+		}
+	}
+
+	// Decode received CAN messages for reference. The DLC must match the
+	// declared length: a short frame is not decoded, and only the declared
+	// bytes of a long one are. The engine command needs just its first byte.
+	if msg, ok := CAN_DBC[frame.ID]; ok && !frame.IsExtended {
+		if frame.Length != msg.DataLen && !(frame.ID == 0x100 && frame.Length >= 1) {
+			if frame.Length < msg.DataLen {
+				warnFrame(frame, "Frame ID 0x%x (%s) ignored: DLC %d, declared %d", frame.ID, msg.Name, frame.Length, msg.DataLen)
+				stats.recordDecodeError(frame.ID)
+				return ""
+			}
+			warnFrame(frame, "Frame ID 0x%x (%s) has DLC %d, declared %d: decoding the declared bytes only", frame.ID, msg.Name, frame.Length, msg.DataLen)
+			stats.recordDecodeError(frame.ID)
+		}
+		data := frame.Data[:min(frame.Length, msg.DataLen)]
+		if !msg.verifyChecksum(data) {
+			warnFrame(frame, "Frame ID 0x%x ignored: bad checksum", frame.ID)
+			stats.recordDecodeError(frame.ID)
+			return ""
+		}
+		counters.check(msg, frame)
+		return msg.decode(data)
+	}
 
-		time.Sleep(1 * time.Second) // Simulate a delay between sensor readings
+	// A strict bus should only carry defined messages
+	if strictMode && !isDiagRequest(frame) {
+		if _, known := MessageByID(frame.ID); !known {
+			warnFrame(frame, "Frame ID 0x%x is not in the DBC", frame.ID)
+			stats.recordDecodeError(frame.ID)
+		}
 	}
+	return ""
+}
+
+// subcommands are the modes selected by the first argument. Without one,
+// the simulator runs.
+var subcommands = map[string]func(args []string) error{
+	"simulate":     runSimulate,
+	"send":         runSend,
+	"monitor":      runMonitor,
+	"tx":           runTx,
+	"busload":      runBusLoad,
+	"verify":       runVerify,
+	"validate-log": runValidateLog,
+	"diff":         runDiff,
+	"diag":         runDiag,
 }
 
-// main function initializes the ECU and starts the listener.
+// main dispatches to the selected subcommand.
 func main() {
+	run, args := runSimulate, os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, ok := subcommands[args[0]]
+		if !ok {
+			log.Fatalf("unknown subcommand %q, expected simulate, send, monitor, tx, busload, verify, validate-log, diff or diag", args[0])
+		}
+		run, args = cmd, args[1:]
+	}
+	if err := run(args); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// runSimulate initializes the ECU and starts the listener.
+func runSimulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	dbcPath := fs.String("dbc", "", "also load message definitions from this DBC file")
+	aliasSpec := fs.String("alias", "", "rename imported DBC messages and signals as Old->New,... (e.g. Eng_Spd_RPM->EngineRPM)")
+	var templates []CANMessage
+	fs.Func("template", "transmit a templated payload as ID[:Name]=bytes, e.g. '0x300=AA ${counter} ${rpm_hi} ${rpm_lo}' (repeatable)", func(spec string) error {
+		msg, err := parseTemplateMessage(spec)
+		templates = append(templates, msg)
+		return err
+	})
+	listOnly := fs.Bool("list", false, "print the message definitions and exit")
+	exportPath := fs.String("export-dbc", "", "write the active messages, after -dbc, -alias, -template and -interval, to this DBC file and exit")
+	scenarioName := fs.String("scenario", "", fmt.Sprintf("run a built-in scenario %v", scenarioNames()))
+	httpAddr := fs.String("http", "", "serve the HTTP API on this address (e.g. :8080)")
+	mirrorIface := fs.String("mirror", "", "retransmit every received frame on this interface (e.g. vcan1)")
+	errLogPath := fs.String("errlog", "", "also write frame warnings to this file")
+	statePath := fs.String("state-file", "", "persist engine hours and DTCs to this JSON file, restoring them on startup")
+	stateInterval := fs.Duration("state-interval", 30*time.Second, "how often to save -state-file")
+	ringSize := fs.Int("ring-size", 1000, "keep this many received frames for post-mortem dumps (0 disables)")
+	fs.StringVar(&frameDumpPath, "ring-dump", frameDumpPath, "file the frame history is dumped to on SIGUSR1 or POST /frames/dump")
+	noiseRate := fs.Float64("noise", 0, "inject this many random frames with unknown IDs per second (0 disables)")
+	faultsPath := fs.String("faults", "", "run a fault-injection timeline file")
+	fs.BoolVar(&j1939Enabled, "j1939", false, "transmit and decode J1939 PGNs (EEC1, ET1)")
+	fs.BoolVar(&strictMode, "strict", false, "treat received frames with IDs not in the DBC as errors")
+	fs.BoolVar(&padFrames, "pad", false, "pad transmitted frames to 8 bytes instead of their message's length")
+	ambient := fs.Int("ambient", vehicle.ambientTemp, "ambient temperature in °C; when set, the engine also starts soaked at it")
+	fs.IntVar(&redlineRPM, "redline", redlineRPM, "engine speed (rpm) where the rev limiter cuts fuel")
+	fs.Float64Var(&coolingRate, "cooling-rate", coolingRate, "fraction of the engine-to-ambient temperature gap lost per minute with the engine off")
+	fs.Float64Var(&engine.Displacement, "displacement", engine.Displacement, "engine displacement in litres; larger engines idle lower and respond more slowly")
+	fs.Float64Var(&engine.Inertia, "inertia", engine.Inertia, "rotating inertia relative to the default engine; higher slows RPM changes")
+	fs.Float64Var(&idleHuntAmplitude, "idle-hunt", idleHuntAmplitude, "amplitude (rpm) of the slow idle speed oscillation (0 disables)")
+	fs.Float64Var(&tipInEnrichment, "tip-in", tipInEnrichment, "O2 spike (percentage points) on a rapid throttle opening; RPM overshoots 10 rpm per point (0 disables)")
+	fs.DurationVar(&idleHuntPeriod, "idle-hunt-period", idleHuntPeriod, "period of the idle speed oscillation")
+	fs.Float64Var(&timeScale, "timescale", timeScale, "run the vehicle model this many times faster than the wall clock; transmit cadence is unchanged")
+	fs.IntVar(&limpHomeRPM, "limp-rpm", limpHomeRPM, "engine speed cap in limp-home mode")
+	fs.Float64Var(&appTolerance, "app-tolerance", appTolerance, "flag the pedal sensors APP1 and APP2 as implausible when they disagree by more than this % of travel")
+	fs.DurationVar(&gaugeSweepTime, "gauge-sweep", 0, "sweep engine speed and fuel level to full scale and back for this long on engine start, e.g. 2s (0 disables)")
+	fs.IntVar(&stallRPM, "stall-rpm", stallRPM, "stall the engine when its speed stays below this (0 disables)")
+	fs.DurationVar(&stallGrace, "stall-grace", stallGrace, "how long the speed must stay below -stall-rpm before the engine stalls")
+	fs.DurationVar(&coastDownTime, "coast-down", coastDownTime, "time for engine speed to fall to zero after switch-off (0 stops instantly)")
+	vin := fs.String("vin", "", "vehicle identification number, checked for a valid check digit (default random)")
+	ecuSerial := fs.String("ecu-serial", "", "ECU serial number (default random)")
+	seed := fs.Int64("seed", 0, "seed the sensor fluctuation and the generated vehicle identity, for a reproducible run (default random)")
+	diagDelay := fs.Duration("diag-delay", 0, "delay before answering diagnostic requests")
+	diagServiceDelays := fs.String("diag-delay-service", "", "per-service diagnostic delays as SID=duration,... (e.g. 0x01=200ms)")
+	fs.Float64Var(&signalChanges.delta, "change-delta", 0, "publish received signal changes larger than this to subscribers")
+	sinkBuffer := fs.Int("sink-buffer", 4096, "frames buffered for the log, mirror and other outputs before the oldest are dropped (0 calls them synchronously)")
+	dupWindow := fs.Duration("dup-window", 0, "flag identical frames repeated within this window as suspected duplicates (e.g. 2ms, 0 disables)")
+	fs.IntVar(&topRates, "top", topRates, "how many of the busiest messages by rate the stats list")
+	logFormat := fs.String("log-format", "text", "how received frames are logged: text, json or logfmt")
+	logModeName := fs.String("logmode", "both", "what the frame log shows of each frame: raw, decoded or both")
+	quietWindow := fs.Duration("quiet-window", 0, "alarm when no frame is received for this long (0 disables)")
+	logGaps := fs.Bool("log-gaps", false, "log the gap between consecutive frames of each ID")
+	metadata := fs.Bool("metadata", false, "broadcast the simulator version, uptime, seed and scenario in SimulatorMetadata")
+	logTx := fs.Bool("log-tx", false, "log every frame the simulation transmits, with when it was handed to the bus")
+	gapTolerance := fs.Float64("gap-tolerance", 0.2, "with -log-gaps, warn when a gap exceeds the cycle time by more than this fraction")
+	fs.BoolVar(&randomPhase, "random-phase", false, "start each message at a random phase within its interval instead of all at once")
+	intervals := fs.String("interval", "", "transmit intervals as Name=duration,... (e.g. EngineRPM=1ms)")
+	var expressions []string
+	fs.Func("expr", "compute a sensor from an expression over t (seconds) and other sensors, e.g. 'EngineRPM=2000 + 500*sin(t/5)' (repeatable)", func(spec string) error {
+		expressions = append(expressions, spec)
+		return nil
+	})
+	injectorUnit := fs.String("injector-unit", "ms", "send injector timing in ms or in crank degrees BTDC (deg)")
+	injectorResolution := fs.Float64("injector-resolution", 1, "injector timing units per bit (e.g. 0.01)")
+	injectorOrder := fs.String("injector-byteorder", "", "byte order of the injector timing value (big or little), -byteorder if unset")
+	byteOrders := fs.String("byteorder", "big", "byte order of multi-byte fields, with per-message overrides, as big|little[,Name=big|little,...]")
+	minIgnition := fs.String("min-ignition", "", "lowest ignition state each message is sent in as Name=off|accessory|run|crank,... (e.g. AmbientTemp=accessory)")
+	fs.DurationVar(&coalesceWindow, "coalesce", 0, "hold value-change events for this long so a burst sends one frame per message with the latest value, e.g. 5ms (0 sends at once)")
+	txRate := fs.Float64("tx-rate", 0, "cap all transmissions at this many frames per second, throttling the highest IDs of each batch first (0 disables)")
+	txBurst := fs.Int("tx-burst", 10, "with -tx-rate, frames that may be sent back to back above the rate")
+	loss := fs.String("loss", "", "randomly drop this percentage of transmitted frames, with per-message overrides, as pct[,Name=pct,...] (e.g. 2,EngineRPM=20)")
+	txTypes := fs.String("tx-type", "", "transmit types as Name=cyclic|onchange|cyclic+event[:threshold],...")
+	checksumSpec := fs.String("checksum", "", "checksum algorithms as Name=xor|sum|crc8-sae-j1850|crc8-autosar-e2e|none,... carried in the last data byte")
+	smooth := fs.String("smooth", "", "low-pass filter sensors as Name=alpha,... (alpha 1 disables)")
+	fs.Parse(args)
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "ambient" {
+			vehicle.ambientTemp = *ambient
+			vehicle.engineTemp = *ambient
+		}
+	})
+
+	if err := setEngineConfig(engine); err != nil {
+		log.Fatalln(err)
+	}
+	if timeScale <= 0 {
+		log.Fatalf("invalid -timescale %g, expected a positive factor", timeScale)
+	}
+	if topRates < 0 {
+		log.Fatalf("invalid -top %d, expected 0 or more", topRates)
+	}
+
+	responseDelays.set(0, *diagDelay)
+	if *diagServiceDelays != "" {
+		delays, err := parseServiceDelays(*diagServiceDelays)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for service, delay := range delays {
+			responseDelays.set(service, delay)
+		}
+	}
+
+	var aliases map[string]string
+	if *aliasSpec != "" {
+		var err error
+		if aliases, err = parseAliases(*aliasSpec); err != nil {
+			return err
+		}
+	}
+	if err := loadMessages(*dbcPath, aliases, templates...); err != nil {
+		return err
+	}
+	if *listOnly {
+		listMessages()
+		return nil
+	}
+
+	var faultTimeline []faultStep
+	if *faultsPath != "" {
+		var err error
+		if faultTimeline, err = loadFaultTimeline(*faultsPath); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	if *intervals != "" {
+		if err := applyIntervals(*intervals); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	// Seeded here rather than at init, so the identity generated from it
+	// is the same on every run with the same -seed
+	if *seed != 0 {
+		simulationSeed = *seed
+	}
+	rand.Seed(simulationSeed)
+	identity = newVehicleIdentity()
+	if *vin != "" {
+		if err := validateVIN(*vin); err != nil {
+			log.Fatalln(err)
+		}
+		identity.VIN = *vin
+	}
+	if *ecuSerial != "" {
+		identity.ECUSerial = *ecuSerial
+	}
+	log.Printf("Vehicle identity: VIN %s, ECU serial %s", identity.VIN, identity.ECUSerial)
+
+	if err := applyByteOrders(*byteOrders); err != nil {
+		log.Fatalln(err)
+	}
+	if *injectorUnit != "ms" || *injectorResolution != 1 || *injectorOrder != "" {
+		if err := applyInjectorTiming(*injectorUnit, *injectorResolution, *injectorOrder); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if err := applyExpressions(expressions); err != nil {
+		log.Fatalln(err)
+	}
+	if *minIgnition != "" {
+		if err := applyMinIgnition(*minIgnition); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if *txTypes != "" {
+		if err := applyTxTypes(*txTypes); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if *checksumSpec != "" {
+		if err := applyChecksums(*checksumSpec); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if *loss != "" {
+		if err := applyLossRates(*loss); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if *txRate > 0 {
+		if *txBurst < 1 {
+			log.Fatalf("invalid -tx-burst %d, expected at least 1", *txBurst)
+		}
+		txLimit = newTokenBucket(*txRate, *txBurst)
+	}
+
+	if *smooth != "" {
+		alphas, err := parseFilterAlphas(*smooth)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		filterAlphas = alphas
+	}
+
+	if *exportPath != "" {
+		if err := exportDBC(*exportPath); err != nil {
+			return err
+		}
+		log.Printf("Exported %d messages to %s", len(CAN_DBC), *exportPath)
+		return nil
+	}
+
+	if *errLogPath != "" {
+		f, err := openErrorLog(*errLogPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer f.Close()
+	}
+
+	if *statePath != "" {
+		LoadState(*statePath)
+	}
+
 	log.Println("Opening RX CAN interface. . .")
 
-	ctx := context.Background()
-	conn, err := socketcan.DialContext(ctx, "can", "vcan0")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	conn, err := socketcan.DialContext(ctx, "can", "vcan0", socketcan.WithReceiveErrorFrames())
 	if err != nil {
 		log.Fatalln("failed to connect to vcan0:", err)
 	}
 	defer conn.Close()
 
+	// Closing the connection on shutdown ends the receive loop below
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	// Counting, duplicate detection and the receive history stay in step
+	// with the bus; outputs that may block are queued behind -sink-buffer
+	addSink(stats)
+	if *dupWindow > 0 {
+		addSink(newDuplicateDetector(*dupWindow))
+	}
+	if *ringSize > 0 {
+		recentFrames = newFrameRing(*ringSize)
+		addSink(recentFrames)
+		go dumpOnSignal(recentFrames, frameDumpPath, "vcan0")
+	}
+	addOutput := addSink
+	var queue *sinkQueue
+	if *sinkBuffer > 0 {
+		queue = newSinkQueue(*sinkBuffer)
+		addOutput = queue.add
+		addSink(queue)
+	}
+	mode, err := parseLogMode(*logModeName)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	frameLog, err := newFrameLogSink(*logFormat, mode, os.Stderr)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	addOutput(frameLog)
+	addOutput(signalChanges)
+	if *logTx {
+		onTransmit = logTransmit
+	}
+
+	injectTx, err := dialTransmitter(ctx, "vcan0")
+	if err != nil {
+		log.Fatalf("message injector: %v", err)
+	}
+	defer injectTx.Close()
+	injector = newMessageInjector(injectTx)
+
+	if *mirrorIface != "" {
+		mirror, err := newFrameMirror(ctx, "vcan0", *mirrorIface)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer mirror.Close()
+		// Not cancelled with ctx, so the queue can still forward the frames
+		// it drains on shutdown
+		addOutput(mirrorSink{ctx: context.WithoutCancel(ctx), mirror: mirror})
+		log.Printf("Mirroring received frames to %s", *mirrorIface)
+	}
+	if queue != nil {
+		go queue.run(ctx)
+	}
+
 	log.Println("Listening on RX vCAN interface...")
 	recv := socketcan.NewReceiver(conn)
+	counters := make(rxCounters)
 
-	for recv.Receive() {
-		frame := recv.Frame()
+	go broadcastAlwaysOn(ctx)
+	if *metadata {
+		go broadcastMetadata(ctx)
+	}
+	if *statePath != "" && *stateInterval > 0 {
+		go saveStatePeriodically(ctx, *statePath, *stateInterval)
+	}
 
-		if frame.Length < 8 {
-			log.Printf("Frame ID 0x%x ignored: DLC less than 8 bytes", frame.ID)
-			continue
+	if *quietWindow > 0 {
+		quietWatchdog = newBusWatchdog(*quietWindow)
+		go quietWatchdog.run(ctx)
+	}
+	var gaps *rxGaps
+	if *logGaps {
+		gaps = newRxGaps(*gapTolerance)
+	}
+
+	diagTx, err := dialTransmitter(ctx, "vcan0")
+	if err != nil {
+		log.Fatalf("diagnostic responder: %v", err)
+	}
+	defer diagTx.Close()
+	responder := newDiagResponder(diagTx)
+
+	// Sinks and everything the API reads are set up by now, so nothing
+	// started below races with their initialization
+	if *httpAddr != "" {
+		startAPI(*httpAddr)
+	}
+
+	if *noiseRate > 0 {
+		go generateNoise(ctx, *noiseRate)
+	}
+
+	if faultTimeline != nil {
+		log.Printf("Running %d-step fault timeline from %s", len(faultTimeline), *faultsPath)
+		go runFaultTimeline(ctx, faultTimeline)
+	}
+
+	if *scenarioName != "" && !startScenario(ctx, *scenarioName) {
+		log.Fatalf("unknown scenario %q, available: %v", *scenarioName, scenarioNames())
+	}
+
+	rxHealth.up()
+	defer rxHealth.down()
+	for ; recv.Receive(); rxHealth.waiting() {
+		rxHealth.busy(time.Now())
+		if quietWatchdog != nil {
+			quietWatchdog.frameReceived()
 		}
 
-		dataFrame := hex.EncodeToString(frame.Data[:frame.Length])
-		dataHex, err := hex.DecodeString(dataFrame)
-		if err != nil {
-			log.Println("Failed to decode paylod into string:", err)
+		if recv.HasErrorFrame() {
+			errFrame := recv.ErrorFrame()
+			log.Printf("Error frame received: %s", errFrame.String())
+			if errFrame.ErrorClass == socketcan.ErrorClassController && errFrame.ControllerError&socketcan.ControllerErrorRxBufferOverflow != 0 {
+				overflows, lost := stats.recordRxOverflow()
+				log.Printf("Warning: receive buffer overflow (#%d), frames were dropped; %d frames known lost from rolling counters so far", overflows, lost)
+			}
 			continue
 		}
 
-		dataStr := string(dataHex)
-
-		// Handle engine on/off command
-		if frame.ID == 0x100 && CAN_DBC[0x100].DataLen == 8 {
-			engineStatus := frame.Data[0] == 1
-			simulationMux.Lock()
-			if engineStatus && !engineOn {
-				engineOn = true
-				go simulateSensors(ctx) // Start sensor simulation
-			} else if !engineStatus && engineOn {
-				engineOn = false
-			}
-			simulationMux.Unlock()
+		frame := recv.Frame()
+		if gaps != nil {
+			gaps.observe(frame, time.Now())
 		}
 
-		// Log received CAN messages for reference
-		if msg, ok := CAN_DBC[frame.ID]; ok && msg.DataLen == 8 {
-			log.Printf("%03x		[%d]	%v		'%s'	'%s'", frame.ID, frame.Length, frame.Data, dataStr, msg.Decode(frame.Data[:msg.DataLen]))
-			continue
+		// Data is a fixed 8-byte array, so an oversized DLC (CAN FD or a
+		// corrupted frame) must be clamped before slicing the payload.
+		if int(frame.Length) > len(frame.Data) {
+			warnFrame(frame, "Frame ID 0x%x has oversized DLC %d, truncating to %d bytes", frame.ID, frame.Length, len(frame.Data))
+			frame.Length = uint8(len(frame.Data))
 		}
 
-		log.Printf("%03x		[%d]	%v		'%s'", frame.ID, frame.Length, frame.Data, dataStr)
+		notifyReceive(frame, handleFrame(ctx, frame, counters, responder))
+	}
+
+	log.Println("Shutting down. . .")
+	// The queue drains before the summary counts its drops and before the
+	// deferred closes take its outputs away. The loop may also have ended
+	// on a receive error, so stop the rest of the simulation first.
+	stop()
+	if queue != nil {
+		queue.wait()
+	}
+	stats.logSummary()
+	if *statePath != "" {
+		if err := SaveState(*statePath); err != nil {
+			log.Printf("failed to save state to %s: %v", *statePath, err)
+		}
 	}
+	return nil
 }
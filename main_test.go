@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForLoops polls until n sensor loops are running or the timeout
+// passes, reporting whether the count was reached.
+func waitForLoops(n int32, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if sensorLoops.Load() == n {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return sensorLoops.Load() == n
+}
+
+func TestEngineRestartRunsOneSensorLoop(t *testing.T) {
+	if err := loadMessages("", nil); err != nil {
+		t.Fatal(err)
+	}
+	savedDial, savedCoast, savedVehicle := busDial, coastDownTime, vehicle
+	busDial = &fakeDialer{}
+	coastDownTime = 50 * time.Millisecond
+	t.Cleanup(func() {
+		busDial, coastDownTime, vehicle = savedDial, savedCoast, savedVehicle
+		engineOn, sensorLoopRunning = false, false
+	})
+
+	// Sample the loop count for the whole test, keeping the highest seen
+	var peak atomic.Int32
+	stop := make(chan struct{})
+	var watching sync.WaitGroup
+	watching.Add(1)
+	go func() {
+		defer watching.Done()
+		for {
+			peak.Store(max(peak.Load(), sensorLoops.Load()))
+			select {
+			case <-stop:
+				return
+			case <-time.After(100 * time.Microsecond):
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	setEngineState(ctx, true)
+	setEngineState(ctx, true)
+	if !waitForLoops(1, time.Second) {
+		t.Fatalf("sensor loops after start = %d, want 1", sensorLoops.Load())
+	}
+	// Built-in messages go out once a second, and the loop needs one pass
+	// to wind the engine down and another to notice it has stopped
+	setEngineState(ctx, false)
+	if !waitForLoops(0, 5*time.Second) {
+		t.Fatalf("sensor loops after coast-down = %d, want 0", sensorLoops.Load())
+	}
+	setEngineState(ctx, true)
+	if !waitForLoops(1, time.Second) {
+		t.Fatalf("sensor loops after restart = %d, want 1", sensorLoops.Load())
+	}
+	// Give a duplicate loop time to show up
+	time.Sleep(200 * time.Millisecond)
+	if n := sensorLoops.Load(); n != 1 {
+		t.Errorf("sensor loops at the end = %d, want 1", n)
+	}
+
+	cancel()
+	if !waitForLoops(0, time.Second) {
+		t.Errorf("sensor loops after cancel = %d, want 0", sensorLoops.Load())
+	}
+	close(stop)
+	watching.Wait()
+	if n := peak.Load(); n > 1 {
+		t.Errorf("peak sensor loops = %d, want at most 1", n)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// SimulatorMetadata identifies the simulator build and configuration that
+// produced a capture. Byte 0 selects the page and the other seven bytes
+// carry its field, numbers big-endian; every page is sent once per
+// interval.
+const (
+	metadataVersion  uint8 = iota // Build version, ASCII, truncated to 7 characters
+	metadataUptime                // Seconds since startup, 32 bits
+	metadataSeed                  // Low 56 bits of the random seed
+	metadataScenario              // Active scenario name, ASCII, empty if none
+	metadataPages
+)
+
+// simulationSeed and activeScenario record how the sensor fluctuation was
+// seeded, for SimulatorMetadata. Guarded by simulationMux.
+var (
+	simulationSeed int64
+	activeScenario string
+)
+
+// encodeMetadataPage builds one page of the SimulatorMetadata payload.
+func encodeMetadataPage(page uint8, uptime time.Duration, seed int64, scenario string) [8]byte {
+	data := [8]byte{page}
+	switch page {
+	case metadataVersion:
+		copy(data[1:], version)
+	case metadataUptime:
+		binary.BigEndian.PutUint32(data[1:5], uint32(uptime/time.Second))
+	case metadataSeed:
+		var seedBytes [8]byte
+		binary.BigEndian.PutUint64(seedBytes[:], uint64(seed))
+		copy(data[1:], seedBytes[1:])
+	case metadataScenario:
+		copy(data[1:], scenario)
+	}
+	return data
+}
+
+func decodeSimulatorMetadata(data []byte) string {
+	switch data[0] {
+	case metadataVersion:
+		return fmt.Sprintf("Simulator Metadata: version %s", strings.TrimRight(string(data[1:8]), "\x00"))
+	case metadataUptime:
+		return fmt.Sprintf("Simulator Metadata: uptime %ds", binary.BigEndian.Uint32(data[1:5]))
+	case metadataSeed:
+		seed := binary.BigEndian.Uint64(append([]byte{0}, data[1:8]...))
+		return fmt.Sprintf("Simulator Metadata: seed 0x%014x", seed)
+	case metadataScenario:
+		if scenario := strings.TrimRight(string(data[1:8]), "\x00"); scenario != "" {
+			return fmt.Sprintf("Simulator Metadata: scenario %s", scenario)
+		}
+		return "Simulator Metadata: no scenario"
+	}
+	return fmt.Sprintf("Simulator Metadata: unknown page %d", data[0])
+}
+
+// broadcastMetadata transmits every SimulatorMetadata page once per
+// message interval until ctx is cancelled.
+func broadcastMetadata(ctx context.Context) {
+	msg, ok := MessageByName("SimulatorMetadata")
+	if !ok {
+		log.Println("No SimulatorMetadata message, not broadcasting metadata")
+		return
+	}
+	tx, err := dialTransmitter(ctx, "vcan0")
+	if err != nil {
+		log.Fatalf("metadata broadcast: %v", err)
+	}
+	defer tx.Close()
+
+	for next := time.Now(); ; next = next.Add(msg.interval()) {
+		if !sleepUntil(ctx, next) {
+			return
+		}
+		simulationMux.Lock()
+		seed, scenario := simulationSeed, activeScenario
+		simulationMux.Unlock()
+
+		for page := range metadataPages {
+			data := encodeMetadataPage(page, stats.uptime(), seed, scenario)
+			tx.transmit(ctx, can.Frame{ID: msg.ID, Length: 8, Data: data})
+		}
+	}
+}
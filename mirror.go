@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"go.einride.tech/can"
+	"go.einride.tech/can/pkg/socketcan"
+)
+
+// mirrorLoopWindow is how long a forwarded frame is remembered. If the same
+// frame shows up on the primary interface again within the window it most
+// likely came back through a bridge on the mirror side and is not forwarded
+// a second time.
+const mirrorLoopWindow = 5 * time.Millisecond
+
+// frameMirror retransmits received frames verbatim on a second interface.
+// It is called from the sinkQueue goroutine, or from the receive loop when
+// -sink-buffer is 0, never from both, so it needs no locking.
+type frameMirror struct {
+	conn   net.Conn
+	tx     *socketcan.Transmitter
+	recent map[can.Frame]time.Time
+}
+
+// newFrameMirror opens the mirror interface. Mirroring onto the primary
+// interface would loop every frame forever and is rejected.
+func newFrameMirror(ctx context.Context, primary, iface string) (*frameMirror, error) {
+	if iface == primary {
+		return nil, fmt.Errorf("mirror interface %s is the primary interface", iface)
+	}
+	conn, err := socketcan.DialContext(ctx, "can", iface)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s for mirroring: %w", iface, err)
+	}
+	return &frameMirror{
+		conn:   conn,
+		tx:     socketcan.NewTransmitter(conn),
+		recent: make(map[can.Frame]time.Time),
+	}, nil
+}
+
+// forward retransmits frame unless it was forwarded moments ago.
+func (m *frameMirror) forward(ctx context.Context, frame can.Frame) error {
+	now := time.Now()
+	for f, sent := range m.recent {
+		if now.Sub(sent) > mirrorLoopWindow {
+			delete(m.recent, f)
+		}
+	}
+	if _, ok := m.recent[frame]; ok {
+		return nil
+	}
+	m.recent[frame] = now
+	return m.tx.TransmitFrame(ctx, frame)
+}
+
+// Close closes the mirror interface.
+func (m *frameMirror) Close() error {
+	return m.conn.Close()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"go.einride.tech/can/pkg/socketcan"
+)
+
+// runMonitor implements "vecu monitor": listen on a bus and decode what is
+// received, without transmitting or simulating anything.
+func runMonitor(args []string) error {
+	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
+	iface := fs.String("iface", "vcan0", "CAN interface to listen on")
+	filterArg := fs.String("filter", "", "only show these IDs, comma separated (e.g. 0x200,0x205)")
+	dbcPath := fs.String("dbc", "", "also decode messages from this DBC file")
+	aliasSpec := fs.String("alias", "", "rename imported DBC messages and signals as Old->New,...")
+	fs.BoolVar(&j1939Enabled, "j1939", false, "decode J1939 PGNs")
+	fs.Parse(args)
+
+	filter, err := parseIDFilter(*filterArg)
+	if err != nil {
+		return err
+	}
+	var aliases map[string]string
+	if *aliasSpec != "" {
+		if aliases, err = parseAliases(*aliasSpec); err != nil {
+			return err
+		}
+	}
+	if err := loadMessages(*dbcPath, aliases); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	conn, err := socketcan.DialContext(ctx, "can", *iface)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", *iface, err)
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	log.Printf("Monitoring %s. . .", *iface)
+	recv := socketcan.NewReceiver(conn)
+	counters := make(rxCounters)
+	for recv.Receive() {
+		frame := recv.Frame()
+		if filter != nil && !filter[frame.ID] {
+			continue
+		}
+		data := frame.Data[:frame.Length]
+
+		if j1939Enabled && frame.IsExtended {
+			if id, msg, ok := decodeJ1939(frame); ok {
+				if frame.Length < 8 {
+					log.Printf("%08x	[%d]	%s		PGN %d (%s): DLC %d, expected 8", frame.ID, frame.Length, hex.EncodeToString(data), id.PGN, msg.Name, frame.Length)
+					continue
+				}
+				log.Printf("%08x	[%d]	%v		PGN %d (%s) SA 0x%02x	'%s'", frame.ID, frame.Length, frame.Data, id.PGN, msg.Name, id.SourceAddress, msg.Decode(frame.Data[:]))
+				continue
+			}
+		}
+
+		msg, ok := MessageByID(frame.ID)
+		if !ok || frame.Length < msg.DataLen {
+			log.Printf("%03x		[%d]	%s", frame.ID, frame.Length, hex.EncodeToString(data))
+			continue
+		}
+		if !msg.verifyChecksum(data) {
+			log.Printf("%03x		[%d]	%s		%s: bad checksum", frame.ID, frame.Length, hex.EncodeToString(data), msg.Name)
+			continue
+		}
+		counters.check(msg, frame)
+		log.Printf("%03x		[%d]	%s		'%s'", frame.ID, frame.Length, hex.EncodeToString(data), msg.decode(data[:msg.DataLen]))
+	}
+	return nil
+}
+
+// parseIDFilter parses a comma-separated list of CAN IDs. An empty list
+// means no filtering and returns nil.
+func parseIDFilter(spec string) (map[uint32]bool, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	ids := make(map[uint32]bool)
+	for _, item := range strings.Split(spec, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(item), 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q in filter", item)
+		}
+		ids[uint32(id)] = true
+	}
+	return ids, nil
+}
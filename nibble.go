@@ -0,0 +1,66 @@
+package main
+
+import "fmt"
+
+// Compact messages often pack two 4-bit values into one byte, the first in
+// the high nibble and the second in the low one.
+
+// splitNibbles returns the high and low nibble of b.
+func splitNibbles(b byte) (hi, lo uint8) {
+	return b >> 4, b & 0x0F
+}
+
+// packNibbles packs hi and lo into one byte, keeping the low 4 bits of
+// each.
+func packNibbles(hi, lo uint8) byte {
+	return hi<<4 | lo&0x0F
+}
+
+// nibbleSignals describes the two nibbles of data[index] as bit signals,
+// so a nibble-packed message also works with the signal tools and DBC
+// export.
+func nibbleSignals(index uint8, hi, lo string) []bitSignal {
+	return []bitSignal{
+		{Name: hi, StartBit: index*8 + 4, Length: 4},
+		{Name: lo, StartBit: index * 8, Length: 4},
+	}
+}
+
+// decodeNibbles returns a decoder that prints the two nibbles of
+// data[index] under the given names.
+func decodeNibbles(name string, index int, hi, lo string) func(data []byte) string {
+	return func(data []byte) string {
+		h, l := splitNibbles(data[index])
+		return fmt.Sprintf("%s: %s=%d, %s=%d", name, hi, h, lo, l)
+	}
+}
+
+// Engine modes reported in the low nibble of PowertrainMode, highest
+// precedence last.
+const (
+	engineModeNormal uint8 = iota
+	engineModeIdle
+	engineModeFuelCut
+	engineModeLimpHome
+)
+
+// engineMode reports the engine operating mode for PowertrainMode.
+func (v vehicleModel) engineMode() uint8 {
+	switch {
+	case v.limpHome:
+		return engineModeLimpHome
+	case v.fuelCut:
+		return engineModeFuelCut
+	case v.idle:
+		return engineModeIdle
+	}
+	return engineModeNormal
+}
+
+// powertrainModeSignals are the PowertrainMode nibbles: the ignition state
+// high and the engine mode low.
+var powertrainModeSignals = nibbleSignals(0, "Ignition", "Mode")
+
+func encodePowertrainMode(v vehicleModel) [8]byte {
+	return [8]byte{packNibbles(uint8(v.ignition), v.engineMode())}
+}
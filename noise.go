@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// randomNoiseFrame returns a standard frame with a random ID that is not
+// in the DBC and not a diagnostic request, and a random DLC and payload.
+func randomNoiseFrame() can.Frame {
+	var frame can.Frame
+	for {
+		frame.ID = uint32(rand.Intn(can.MaxID + 1))
+		if _, known := MessageByID(frame.ID); !known && !isDiagRequest(frame) && frame.ID != diagResponseID {
+			break
+		}
+	}
+	frame.Length = uint8(rand.Intn(9))
+	rand.Read(frame.Data[:frame.Length])
+	return frame
+}
+
+// generateNoise transmits rate random unknown frames per second until ctx
+// is cancelled, to exercise how consumers handle unexpected traffic.
+func generateNoise(ctx context.Context, rate float64) {
+	tx, err := dialTransmitter(ctx, "vcan0")
+	if err != nil {
+		log.Fatalf("noise generator: %v", err)
+	}
+	defer tx.Close()
+
+	interval := time.Duration(float64(time.Second) / rate)
+	log.Printf("Injecting bus noise at %g frames/s", rate)
+	for next := time.Now(); ; next = next.Add(interval) {
+		if !sleepUntil(ctx, next) {
+			return
+		}
+		tx.transmit(ctx, randomNoiseFrame())
+	}
+}
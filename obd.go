@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// Diagnostic CAN IDs: OBD-II functional (broadcast) and physical requests
+// to the engine ECU, and its responses.
+const (
+	diagFunctionalRequestID = 0x7DF
+	diagPhysicalRequestID   = 0x7E0
+	diagResponseID          = 0x7E8
+)
+
+// Diagnostic services handled by the responder.
+const (
+	serviceCurrentData          = 0x01 // OBD-II: show current data
+	serviceReadDataByIdentifier = 0x22 // UDS: read data by identifier
+	serviceTesterPresent        = 0x3E // UDS: tester present
+	negativeResponse            = 0x7F
+)
+
+// UDS negative response codes.
+const (
+	nrcServiceNotSupported = 0x11
+	nrcIncorrectLength     = 0x13
+	nrcRequestOutOfRange   = 0x31
+)
+
+// obdPIDs answers the supported mode 01 PIDs from the model, returning the
+// data bytes that follow the PID in the response.
+var obdPIDs = map[byte]func(v vehicleModel) []byte{
+	0x05: func(v vehicleModel) []byte { return []byte{byte(min(max(v.engineTemp+40, 0), 255))} },
+	0x0C: func(v vehicleModel) []byte { rpm := uint16(v.engineRPM * 4); return []byte{byte(rpm >> 8), byte(rpm)} },
+	0x11: func(v vehicleModel) []byte { return []byte{byte(v.throttlePosition * 255 / 100)} },
+	0x2F: func(v vehicleModel) []byte { return []byte{byte(v.fuelTankLevel * 255 / 100)} },
+	0x42: func(v vehicleModel) []byte {
+		mv := uint16(v.batteryVoltage * 1000)
+		return []byte{byte(mv >> 8), byte(mv)}
+	},
+	0x46: func(v vehicleModel) []byte { return []byte{byte(min(max(v.ambientTemp+40, 0), 255))} },
+}
+
+// supportedPIDs builds the PID 0x00 bitmask of supported PIDs 0x01-0x20.
+func supportedPIDs() []byte {
+	var mask uint32
+	for pid := range obdPIDs {
+		if pid >= 0x01 && pid <= 0x20 {
+			mask |= 1 << (32 - uint32(pid))
+		}
+	}
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, mask)
+	return data
+}
+
+// diagDelays holds artificial response delays, globally and per service.
+type diagDelays struct {
+	mu        sync.Mutex
+	global    time.Duration
+	byService map[byte]time.Duration
+}
+
+// responseDelays is the delay configuration, set by -diag-delay,
+// -diag-delay-service and the 0x103 DiagDelay control frame.
+var responseDelays = &diagDelays{byService: make(map[byte]time.Duration)}
+
+// forService returns the delay for a service, falling back to the global delay.
+func (d *diagDelays) forService(service byte) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if delay, ok := d.byService[service]; ok {
+		return delay
+	}
+	return d.global
+}
+
+// set configures the delay for a service, or the global delay for service 0.
+func (d *diagDelays) set(service byte, delay time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if service == 0 {
+		d.global = delay
+		return
+	}
+	d.byService[service] = delay
+}
+
+// parseServiceDelays parses a -diag-delay-service value like "0x01=200ms,0x3E=1s".
+func parseServiceDelays(spec string) (map[byte]time.Duration, error) {
+	delays := make(map[byte]time.Duration)
+	for _, item := range strings.Split(spec, ",") {
+		sid, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid service delay %q, expected SID=duration", item)
+		}
+		service, err := strconv.ParseUint(sid, 0, 8)
+		if err != nil || service == 0 {
+			return nil, fmt.Errorf("invalid service ID %q", sid)
+		}
+		delay, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delay for service %s: %w", sid, err)
+		}
+		delays[byte(service)] = delay
+	}
+	return delays, nil
+}
+
+// handleDiagDelayCommand applies a DiagDelay control frame: byte 0 is the
+// service ID (0 for the global delay), bytes 1-2 the delay in ms, in the
+// DiagDelay byte order.
+func handleDiagDelayCommand(frame can.Frame) {
+	if frame.Length < 3 {
+		warnFrame(frame, "Frame ID 0x%x ignored: diagnostic delay needs 3 data bytes", frame.ID)
+		return
+	}
+	service := frame.Data[0]
+	delay := time.Duration(byteOrderOf("DiagDelay").Uint16(frame.Data[1:3])) * time.Millisecond
+	responseDelays.set(service, delay)
+	if service == 0 {
+		log.Printf("Diagnostic response delay set to %s", delay)
+	} else {
+		log.Printf("Diagnostic response delay for service 0x%02x set to %s", service, delay)
+	}
+}
+
+func decodeDiagDelay(data []byte) string {
+	delay := byteOrderOf("DiagDelay").Uint16(data[1:3])
+	if data[0] == 0 {
+		return fmt.Sprintf("Diagnostic Delay: %d ms", delay)
+	}
+	return fmt.Sprintf("Diagnostic Delay: service 0x%02x %d ms", data[0], delay)
+}
+
+// diagResponder answers OBD-II and UDS single-frame requests, with
+// multi-frame ISO-TP responses where they do not fit a frame. Responses are
+// sent from their own goroutines, so the transmitter is shared under mu.
+// Flow control frames from the tester are passed to the sending goroutine
+// through flowControl.
+type diagResponder struct {
+	mu          sync.Mutex
+	tx          *busTransmitter
+	flowControl chan can.Frame
+}
+
+func newDiagResponder(tx *busTransmitter) *diagResponder {
+	return &diagResponder{tx: tx, flowControl: make(chan can.Frame, 1)}
+}
+
+// isDiagRequest reports whether a frame is addressed to the responder.
+func isDiagRequest(frame can.Frame) bool {
+	return !frame.IsExtended && (frame.ID == diagFunctionalRequestID || frame.ID == diagPhysicalRequestID)
+}
+
+// respond answers a request after the configured delay and records the
+// time from receipt to the response being sent. It runs in its own
+// goroutine and gives up if ctx is cancelled while waiting.
+func (d *diagResponder) respond(ctx context.Context, req can.Frame) {
+	received := time.Now()
+	if req.Data[0]>>4 == isoTPFlowControl && req.Length >= 3 {
+		select {
+		case d.flowControl <- req:
+		default: // No transfer is waiting for it
+		}
+		return
+	}
+	length := int(req.Data[0] & 0x0F)
+	if req.Data[0]>>4 != isoTPSingleFrame || length < 1 || length > 7 || int(req.Length) < length+1 {
+		return // Only single frame requests are supported
+	}
+	payload := req.Data[1 : 1+length]
+	service := payload[0]
+
+	response := d.response(payload)
+	if response == nil {
+		return
+	}
+
+	if delay := responseDelays.forService(service); delay > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.send(ctx, response); err != nil {
+		log.Printf("diagnostic response to service 0x%02x failed: %v", service, err)
+		return
+	}
+	stats.recordDiagLatency(time.Since(received))
+}
+
+// response builds the response payload for a request payload, or nil when
+// the request deserves no answer.
+func (d *diagResponder) response(payload []byte) []byte {
+	service := payload[0]
+	switch service {
+	case serviceCurrentData:
+		if len(payload) < 2 {
+			return []byte{negativeResponse, service, nrcRequestOutOfRange}
+		}
+		pid := payload[1]
+		if pid == 0x00 {
+			return append([]byte{service + 0x40, pid}, supportedPIDs()...)
+		}
+		value, ok := obdPIDs[pid]
+		if !ok {
+			return []byte{negativeResponse, service, nrcRequestOutOfRange}
+		}
+		simulationMux.Lock()
+		state := vehicle
+		state.applyOverrides()
+		simulationMux.Unlock()
+		return append([]byte{service + 0x40, pid}, value(state)...)
+	case serviceReadDataByIdentifier:
+		if len(payload) != 3 {
+			return []byte{negativeResponse, service, nrcIncorrectLength}
+		}
+		data, ok := readDataByIdentifier(binary.BigEndian.Uint16(payload[1:3]))
+		if !ok {
+			return []byte{negativeResponse, service, nrcRequestOutOfRange}
+		}
+		return append([]byte{service + 0x40, payload[1], payload[2]}, data...)
+	case serviceTesterPresent:
+		if len(payload) > 1 && payload[1]&0x80 != 0 {
+			return nil // Positive response suppressed
+		}
+		return []byte{service + 0x40, 0x00}
+	}
+	return []byte{negativeResponse, service, nrcServiceNotSupported}
+}
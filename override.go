@@ -0,0 +1,53 @@
+package main
+
+import "fmt"
+
+// sensorFields maps each overridable sensor message name to the model
+// reading it transmits. The pedal sensors are named by signal, so each can
+// be forced to disagree with the other.
+var sensorFields = map[string]func(v *vehicleModel, value float64){
+	"EngineTempSensor":     func(v *vehicleModel, value float64) { v.engineTemp = int(value) },
+	"InjectorTimingSensor": func(v *vehicleModel, value float64) { v.injectorTiming = int(value) },
+	"OxygenSensor":         func(v *vehicleModel, value float64) { v.oxygenSensor = int(value) },
+	"FuelTankLevel":        func(v *vehicleModel, value float64) { v.fuelTankLevel = int(value) },
+	"ThrottlePosition":     func(v *vehicleModel, value float64) { v.throttlePosition = int(value) },
+	"APP1":                 func(v *vehicleModel, value float64) { v.app1 = value },
+	"APP2":                 func(v *vehicleModel, value float64) { v.app2 = value },
+	"EngineRPM":            func(v *vehicleModel, value float64) { v.engineRPM = int(value) },
+	"MassAirFlow":          func(v *vehicleModel, value float64) { v.massAirFlow = float32(value) },
+	"AmbientTemp":          func(v *vehicleModel, value float64) { v.ambientTemp = int(value) },
+	"BatteryVoltage":       func(v *vehicleModel, value float64) { v.batteryVoltage = float32(value) },
+}
+
+// sensorOverrides holds forced sensor values by message name. Guarded by
+// simulationMux.
+var sensorOverrides = make(map[string]float64)
+
+// setOverride forces the transmitted value of a sensor until cleared.
+func setOverride(name string, value float64) error {
+	if _, ok := sensorFields[name]; !ok {
+		return fmt.Errorf("unknown sensor %q", name)
+	}
+	simulationMux.Lock()
+	defer simulationMux.Unlock()
+	sensorOverrides[name] = value
+	return nil
+}
+
+// clearOverride hands a sensor back to the model. It reports whether an
+// override was active.
+func clearOverride(name string) bool {
+	simulationMux.Lock()
+	defer simulationMux.Unlock()
+	_, ok := sensorOverrides[name]
+	delete(sensorOverrides, name)
+	return ok
+}
+
+// applyOverrides replaces model readings with any forced values. The caller
+// must hold simulationMux.
+func (v *vehicleModel) applyOverrides() {
+	for name, value := range sensorOverrides {
+		sensorFields[name](v, value)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// The accelerator pedal position is measured by two redundant sensors.
+// APP1 rises from appMinVolts at rest to appMaxVolts at full travel and
+// APP2 falls across the same range, so the two always add up to
+// appMinVolts+appMaxVolts and a sensor fault shows as a disagreement.
+const (
+	appMinVolts = 0.5
+	appMaxVolts = 4.5
+)
+
+// appTolerance is how far apart, in % of pedal travel, the two sensors may
+// read before the decoder flags them as implausible. Set with
+// -app-tolerance.
+var appTolerance = 5.0
+
+// pedalVoltages returns the APP1 and APP2 voltages for a throttle
+// position in %.
+func pedalVoltages(throttle int) (app1, app2 float64) {
+	travel := float64(throttle) / 100 * (appMaxVolts - appMinVolts)
+	return appMinVolts + travel, appMaxVolts - travel
+}
+
+// pedalPositions converts the sensor voltages back to pedal travel in %.
+func pedalPositions(app1, app2 float64) (pos1, pos2 float64) {
+	span := appMaxVolts - appMinVolts
+	return (app1 - appMinVolts) / span * 100, (appMaxVolts - app2) / span * 100
+}
+
+// throttlePositionSignals are the pedal sensor voltages sent after the
+// throttle position.
+var throttlePositionSignals = []bitSignal{
+	{Name: "APP1", StartBit: 8, Length: 8, Factor: 0.02, Unit: "V", Comment: "Accelerator pedal sensor 1, rising with travel"},
+	{Name: "APP2", StartBit: 16, Length: 8, Factor: 0.02, Unit: "V", Comment: "Accelerator pedal sensor 2, falling with travel"},
+}
+
+// decodeThrottlePosition prints the throttle position and both pedal
+// sensors, flagging them when they disagree by more than appTolerance.
+func decodeThrottlePosition(data []byte) string {
+	app1 := throttlePositionSignals[0].physical(data)
+	app2 := throttlePositionSignals[1].physical(data)
+	text := fmt.Sprintf("Throttle Position: %d %%, APP1 %.2f V, APP2 %.2f V", data[0], app1, app2)
+	if pos1, pos2 := pedalPositions(app1, app2); math.Abs(pos1-pos2) > appTolerance {
+		text += fmt.Sprintf(", APP implausible (%.1f%% apart)", math.Abs(pos1-pos2))
+	}
+	return text
+}
+
+// encodeThrottlePosition fills in the pedal sensors; the throttle
+// position itself is the message value.
+func encodeThrottlePosition(v vehicleModel) [8]byte {
+	var data [8]byte
+	for i, volts := range []float64{v.app1, v.app2} {
+		if raw, err := throttlePositionSignals[i].rawValue(volts); err == nil {
+			throttlePositionSignals[i].insert(&data, raw)
+		}
+	}
+	return data
+}
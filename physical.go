@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Physical-value messages carry a single unsigned raw value in their first
+// ValueLen bytes, in the message byte order (see byteorder.go). The
+// physical value is raw*Factor + Offset and is rendered by substituting
+// it, rounded to Precision decimals, for "{value}" in Format and appending
+// Unit.
+
+// factor returns the message scaling factor, treating zero as unscaled.
+func (m CANMessage) factor() float64 {
+	if m.Factor == 0 {
+		return 1
+	}
+	return m.Factor
+}
+
+// physical extracts the scaled physical value from a payload.
+func (m CANMessage) physical(data []byte) float64 {
+	var raw uint64
+	for i := range int(m.ValueLen) {
+		raw = raw<<8 | uint64(data[m.valueByte(i)])
+	}
+	return float64(raw)*m.factor() + m.Offset
+}
+
+// formatValue renders a physical value with the message format, followed
+// by its unit.
+func (m CANMessage) formatValue(value float64) string {
+	text := strings.ReplaceAll(m.Format, "{value}", strconv.FormatFloat(value, 'f', m.Precision, 64))
+	if m.Unit != "" {
+		text += " " + m.Unit
+	}
+	return text
+}
+
+// encodePhysical packs a physical value into the raw payload, saturating at
+// the limits of the raw field.
+func (m CANMessage) encodePhysical(value float64) [8]byte {
+	maxRaw := math.Pow(2, float64(8*m.ValueLen)) - 1
+	raw := uint64(math.Min(math.Max(math.Round((value-m.Offset)/m.factor()), 0), maxRaw))
+
+	var data [8]byte
+	for i := int(m.ValueLen) - 1; i >= 0; i-- {
+		data[m.valueByte(i)] = byte(raw)
+		raw >>= 8
+	}
+	return data
+}
+
+// valueByte returns the payload byte holding byte i of the raw value,
+// counting from the most significant.
+func (m CANMessage) valueByte(i int) int {
+	if m.littleEndian() {
+		return int(m.ValueLen) - 1 - i
+	}
+	return i
+}
+
+// decode renders a payload for the log, preferring the physical format.
+func (m CANMessage) decode(data []byte) string {
+	if m.Format != "" {
+		return m.formatValue(m.physical(data))
+	}
+	return m.Decode(data)
+}
+
+// encode builds the simulated payload for a message from the model,
+// smoothing physical values through filter. A physical-value message with
+// an Encode as well takes the bytes after its value from Encode.
+func (m CANMessage) encode(v vehicleModel, filter *signalFilter) [8]byte {
+	if m.Value != nil {
+		data := m.encodePhysical(filter.apply(m.Name, m.Value(v)))
+		if m.Encode != nil {
+			extra := m.Encode(v)
+			copy(data[m.ValueLen:], extra[m.ValueLen:])
+		}
+		return data
+	}
+	return m.Encode(v)
+}
+
+// Model readings transmitted by the physical-value messages.
+func engineTempValue(v vehicleModel) float64       { return float64(v.engineTemp) }
+func injectorTimingValue(v vehicleModel) float64   { return float64(v.injectorTiming) }
+func oxygenSensorValue(v vehicleModel) float64     { return float64(v.oxygenSensor) }
+func fuelTankLevelValue(v vehicleModel) float64    { return float64(v.fuelTankLevel) }
+func throttlePositionValue(v vehicleModel) float64 { return float64(v.throttlePosition) }
+func engineRPMValue(v vehicleModel) float64        { return float64(v.engineRPM) }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// plausibilityRule flags an implausible combination of related signals in
+// the vehicle model. Check reports whether the rule is violated for the
+// given state and threshold.
+type plausibilityRule struct {
+	Name      string
+	FaultCode uint8
+	Threshold int
+	Check     func(v vehicleModel, threshold int) bool
+}
+
+// plausibilityRules are evaluated on every simulation tick.
+var plausibilityRules = []plausibilityRule{
+	{
+		// RPM well above idle with the throttle closed.
+		Name: "RPMWithoutThrottle", FaultCode: 0x01, Threshold: 1200,
+		Check: func(v vehicleModel, threshold int) bool {
+			return v.throttlePosition == 0 && v.engineRPM > threshold
+		},
+	},
+	{
+		// Throttle open but (almost) no air entering the engine.
+		Name: "ThrottleWithoutAirFlow", FaultCode: 0x02, Threshold: 20,
+		Check: func(v vehicleModel, threshold int) bool {
+			return v.throttlePosition > threshold && v.massAirFlow < 1
+		},
+	},
+	{
+		// Engine turning fast while the temperature reads below freezing.
+		Name: "RPMWithFrozenCoolant", FaultCode: 0x03, Threshold: 4000,
+		Check: func(v vehicleModel, threshold int) bool {
+			return v.engineRPM > threshold && v.engineTemp < 0
+		},
+	},
+	{
+		// Not implausible as such: the ECU reports limp-home mode as a fault.
+		Name: "LimpHome", FaultCode: limpHomeFaultCode,
+		Check: func(v vehicleModel, threshold int) bool {
+			return v.limpHome
+		},
+	},
+}
+
+// checkPlausibility returns the rules violated by the given state.
+func checkPlausibility(v vehicleModel) []plausibilityRule {
+	var violated []plausibilityRule
+	for _, rule := range plausibilityRules {
+		if rule.Check(v, rule.Threshold) {
+			violated = append(violated, rule)
+		}
+	}
+	return violated
+}
+
+// plausibilityRuleByCode returns the rule registered under a fault code.
+func plausibilityRuleByCode(code uint8) (plausibilityRule, bool) {
+	for _, rule := range plausibilityRules {
+		if rule.FaultCode == code {
+			return rule, true
+		}
+	}
+	return plausibilityRule{}, false
+}
+
+func decodePlausibilityFault(data []byte) string {
+	if rule, ok := plausibilityRuleByCode(data[0]); ok {
+		return fmt.Sprintf("Plausibility Fault: 0x%02x %s", data[0], rule.Name)
+	}
+	return fmt.Sprintf("Plausibility Fault: 0x%02x", data[0])
+}
+
+// maxReportedFaults is how many fault codes fit in an ActiveFaults frame
+// after the count byte.
+const maxReportedFaults = 7
+
+// encodeActiveFaults packs the active fault count and up to
+// maxReportedFaults codes, loosely modeled on J1939 DM1.
+func encodeActiveFaults(codes []uint8) [8]byte {
+	var data [8]byte
+	data[0] = byte(len(codes))
+	copy(data[1:], codes)
+	return data
+}
+
+func decodeActiveFaults(data []byte) string {
+	count := int(data[0])
+	names := make([]string, 0, count)
+	for _, code := range data[1 : 1+min(count, maxReportedFaults)] {
+		names = append(names, faultName(code))
+	}
+	return fmt.Sprintf("Active Faults: %d [%s]", count, strings.Join(names, ", "))
+}
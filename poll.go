@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// handleRemoteFrame answers a remote frame (RTR) for a simulated sensor by
+// transmitting its current value at once, outside its periodic cadence. It
+// returns the decoded text of the request.
+func handleRemoteFrame(ctx context.Context, frame can.Frame) string {
+	msg, ok := CAN_DBC[frame.ID]
+	if !ok || frame.IsExtended {
+		return ""
+	}
+	if msg.Encode == nil && msg.Value == nil && msg.Template == nil {
+		return fmt.Sprintf("RTR %s: not transmitted by the simulator", msg.Name)
+	}
+	go func() {
+		if _, err := injector.AnswerPoll(ctx, msg); err != nil {
+			log.Printf("RTR %s: %v", msg.Name, err)
+		}
+	}()
+	return fmt.Sprintf("RTR %s", msg.Name)
+}
+
+// AnswerPoll transmits one frame of msg built from the current vehicle
+// state, as the periodic loops would. Messages that are not currently
+// being transmitted, because the engine or ignition is off or the sensor
+// has dropped out, are not answered.
+func (j *messageInjector) AnswerPoll(ctx context.Context, msg CANMessage) (can.Frame, error) {
+	simulationMux.Lock()
+	running := engineOn
+	state := vehicle
+	state.applyOverrides()
+	simulationMux.Unlock()
+
+	if msg.RequiresEngine && !running {
+		return can.Frame{}, fmt.Errorf("not answered, the engine is off")
+	}
+	if state.ignition < msg.MinIgnition {
+		return can.Frame{}, fmt.Errorf("not answered, the ignition is %s", state.ignition)
+	}
+	if droppedOut(msg.Name, time.Now()) {
+		return can.Frame{}, fmt.Errorf("not answered, the sensor has dropped out")
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var data [8]byte
+	if msg.Template != nil {
+		data = msg.Template.render(state, j.counters.nextByte(msg.ID))
+	} else {
+		data = msg.encode(state, j.filter)
+		msg.applyCounter(&data, j.counters.next(msg))
+		msg.applyChecksum(&data)
+	}
+	frame := msg.frame(data)
+	return frame, j.tx.transmit(ctx, frame)
+}
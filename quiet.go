@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// busWatchdog raises an alarm when no frame of any kind has been received
+// for a whole window. It is independent of per-message staleness.
+type busWatchdog struct {
+	window    time.Duration
+	lastFrame atomic.Int64 // Unix nanoseconds of the last received frame
+	quiet     atomic.Bool
+}
+
+// quietWatchdog is the active watchdog, nil unless -quiet-window is set.
+var quietWatchdog *busWatchdog
+
+func newBusWatchdog(window time.Duration) *busWatchdog {
+	w := &busWatchdog{window: window}
+	w.lastFrame.Store(time.Now().UnixNano())
+	return w
+}
+
+// frameReceived resets the quiet timer.
+func (w *busWatchdog) frameReceived() {
+	w.lastFrame.Store(time.Now().UnixNano())
+	if w.quiet.CompareAndSwap(true, false) {
+		log.Println("Bus traffic resumed")
+	}
+}
+
+// isQuiet reports whether the bus is currently considered silent.
+func (w *busWatchdog) isQuiet() bool {
+	return w != nil && w.quiet.Load()
+}
+
+// run checks for silence several times per window until ctx is cancelled.
+func (w *busWatchdog) run(ctx context.Context) {
+	ticker := time.NewTicker(max(w.window/4, 10*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		silent := time.Since(time.Unix(0, w.lastFrame.Load()))
+		if silent >= w.window && w.quiet.CompareAndSwap(false, true) {
+			log.Printf("ALARM: bus quiet, no frames received for %s", silent.Round(time.Millisecond))
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket caps the overall rate of transmitted frames. It holds up to
+// burst tokens, refilled at rate per second, and every frame takes one.
+// It is shared by every busTransmitter.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// allow takes a token if one is available.
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// txLimit is the transmit rate ceiling set with -tx-rate, nil for none. It
+// is taken in busTransmitter.transmit, so scheduled messages, J1939,
+// faults, metadata, diagnostic responses, injected frames and noise all
+// count against it. The scheduler hands out due messages in ID order, so
+// when the ceiling is reached the lowest IDs of a batch, which win
+// arbitration on the bus too, are sent and the rest are throttled.
+var txLimit *tokenBucket
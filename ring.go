@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// recordedFrame is a received frame and when it arrived.
+type recordedFrame struct {
+	At    time.Time
+	Frame can.Frame
+}
+
+// frameRing keeps the most recent received frames, overwriting the oldest
+// once full, so the traffic leading up to an anomaly can be dumped after
+// the fact.
+type frameRing struct {
+	mu     sync.Mutex
+	frames []recordedFrame
+	next   int  // Slot the next frame is written to
+	full   bool // Every slot has been written at least once
+}
+
+// recentFrames is the receive history, nil when -ring-size is 0.
+var recentFrames *frameRing
+
+// frameDumpPath is where the history is written, set with -ring-dump.
+var frameDumpPath = "vecu-frames.log"
+
+// newFrameRing returns a ring holding up to size frames.
+func newFrameRing(size int) *frameRing {
+	return &frameRing{frames: make([]recordedFrame, size)}
+}
+
+// add records a received frame. It is a no-op on a nil ring.
+func (r *frameRing) add(frame can.Frame, at time.Time) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frames[r.next] = recordedFrame{At: at, Frame: frame}
+	r.next = (r.next + 1) % len(r.frames)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// snapshot returns the recorded frames, oldest first.
+func (r *frameRing) snapshot() []recordedFrame {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]recordedFrame(nil), r.frames[:r.next]...)
+	}
+	return append(append([]recordedFrame(nil), r.frames[r.next:]...), r.frames[:r.next]...)
+}
+
+// dump writes the recorded frames to path in candump log format, replacing
+// any previous dump, and returns how many were written.
+func (r *frameRing) dump(path, iface string) (int, error) {
+	frames := r.snapshot()
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create frame dump: %w", err)
+	}
+	defer f.Close()
+	for _, rec := range frames {
+		ts := rec.At.UnixMicro()
+		if _, err := fmt.Fprintf(f, "(%d.%06d) %s %s\n", ts/1e6, ts%1e6, iface, rec.Frame.String()); err != nil {
+			return 0, fmt.Errorf("failed to write frame dump: %w", err)
+		}
+	}
+	return len(frames), f.Close()
+}
+
+// dumpOnSignal writes the ring to path each time the process receives
+// SIGUSR1.
+func dumpOnSignal(r *frameRing, path, iface string) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1)
+	for range sigs {
+		n, err := r.dump(path, iface)
+		if err != nil {
+			log.Printf("Frame dump failed: %v", err)
+			continue
+		}
+		log.Printf("Dumped last %d frames to %s", n, path)
+	}
+}
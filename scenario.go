@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// scenario is a built-in, repeatable sequence of model changes selectable
+// with -scenario. Seed makes the sensor fluctuation reproducible.
+type scenario struct {
+	Description string
+	Seed        int64
+	Run         func(ctx context.Context)
+}
+
+// scenarios is the registry of built-in scenarios, keyed by name.
+var scenarios = map[string]scenario{
+	"coldstart": {
+		Description: "start a cold engine, warm up into O2 closed loop, then idle",
+		Seed:        1,
+		Run:         runColdStart,
+	},
+}
+
+// scenarioNames returns the registered scenario names in sorted order.
+func scenarioNames() []string {
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// startScenario seeds the simulation and runs the named scenario in the
+// background.
+func startScenario(ctx context.Context, name string) bool {
+	sc, ok := scenarios[name]
+	if !ok {
+		return false
+	}
+	simulationMux.Lock()
+	simulationSeed, activeScenario = sc.Seed, name
+	simulationMux.Unlock()
+	rand.Seed(sc.Seed)
+	log.Printf("Scenario %s: %s", name, sc.Description)
+	go sc.Run(ctx)
+	return true
+}
+
+// runColdStart starts the engine at a low ambient temperature and logs the
+// warm-up and O2 closed-loop transitions before settling at idle.
+func runColdStart(ctx context.Context) {
+	const ambient = -10
+
+	simulationMux.Lock()
+	vehicle.ambientTemp = ambient
+	vehicle.engineTemp = ambient
+	vehicle.engineOffAt = time.Time{}
+	vehicle.throttleClosed = false
+	simulationMux.Unlock()
+	log.Printf("Scenario coldstart: ambient temperature set to %d °C", ambient)
+
+	setEngineState(ctx, true)
+	log.Println("Scenario coldstart: engine started")
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	var warm, closedLoop bool
+	for !warm || !closedLoop {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		simulationMux.Lock()
+		temp, loop := vehicle.engineTemp, vehicle.closedLoop
+		simulationMux.Unlock()
+
+		if loop && !closedLoop {
+			closedLoop = true
+			log.Printf("Scenario coldstart: O2 closed loop entered at %d °C", temp)
+		}
+		if temp >= operatingTemp && !warm {
+			warm = true
+			log.Printf("Scenario coldstart: warm-up complete at %d °C", temp)
+		}
+	}
+
+	simulationMux.Lock()
+	vehicle.throttleClosed = true
+	simulationMux.Unlock()
+	log.Println("Scenario coldstart: idling")
+}
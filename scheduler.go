@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultInterval = 1 * time.Second // Transmit interval of messages without one
+	// spinThreshold is how long before a deadline the scheduler stops
+	// sleeping and spins instead. Timer wake-ups are only accurate to
+	// around a millisecond; spinning the remainder keeps sub-millisecond
+	// cadences on time at the cost of some CPU per send.
+	spinThreshold = 500 * time.Microsecond
+)
+
+// interval returns how often the message is transmitted.
+func (m CANMessage) interval() time.Duration {
+	if m.Interval <= 0 {
+		return defaultInterval
+	}
+	return m.Interval
+}
+
+// txType selects when a message is transmitted, set with -tx-type.
+type txType uint8
+
+const (
+	txCyclic      txType = iota // On its interval only
+	txOnChange                  // Once at start, then only when its value changes
+	txCyclicEvent               // On its interval, and at once when its value changes
+)
+
+var txTypeNames = map[string]txType{"cyclic": txCyclic, "onchange": txOnChange, "cyclic+event": txCyclicEvent}
+
+// eventPollInterval is how often the scheduler wakes to look for value
+// changes when any message is event-driven. It matches the model tick, the
+// fastest a value can change.
+const eventPollInterval = modelTickInterval
+
+// coalesceWindow delays an event by up to this long, set with -coalesce,
+// so that changes arriving in a burst go out as one frame per message
+// carrying the latest value. Zero sends each event at once.
+var coalesceWindow time.Duration
+
+// scheduledMessage is a message and the absolute time it is next due. An
+// on-change message has no next time after its first send. The last value
+// or payload sent is kept to detect changes, and pending is when a change
+// not yet sent was first seen.
+type scheduledMessage struct {
+	msg      CANMessage
+	next     time.Time
+	hasEvent bool
+	value    float64
+	data     [8]byte
+	pending  time.Time
+}
+
+// txScheduler releases messages on their own cadence. Deadlines advance by
+// exactly one interval per send rather than being measured from the time of
+// the send, so jitter in a single wake-up never accumulates into drift.
+type txScheduler struct {
+	entries []scheduledMessage
+	events  bool // Some message is event-driven, see withEvents
+}
+
+// randomPhase offsets each message's first send by a random fraction of
+// its interval, set with -random-phase, so that messages sharing a cadence
+// do not all land on the bus at once.
+var randomPhase bool
+
+// newTxScheduler schedules every message to be sent first at start, or at
+// a random phase within its first interval with randomPhase.
+func newTxScheduler(msgs []CANMessage, start time.Time) *txScheduler {
+	s := &txScheduler{}
+	for _, msg := range msgs {
+		next := start
+		if randomPhase {
+			next = next.Add(time.Duration(rand.Int63n(int64(msg.interval()))))
+		}
+		s.entries = append(s.entries, scheduledMessage{msg: msg, next: next})
+		s.events = s.events || msg.TxType != txCyclic
+	}
+	return s
+}
+
+// next waits for the earliest deadline and returns every message due by
+// then, in ID order. With event-driven messages it also wakes every
+// eventPollInterval, and when the coalescing window of a pending change
+// closes, possibly with nothing due, so the caller can look for changes
+// with withEvents. It returns false once ctx is cancelled.
+func (s *txScheduler) next(ctx context.Context) ([]CANMessage, bool) {
+	deadline := time.Now().Add(defaultInterval)
+	if s.events {
+		deadline = time.Now().Add(eventPollInterval)
+	}
+	for _, e := range s.entries {
+		if !e.next.IsZero() && e.next.Before(deadline) {
+			deadline = e.next
+		}
+		if flush := e.pending.Add(coalesceWindow); !e.pending.IsZero() && flush.Before(deadline) {
+			deadline = flush
+		}
+	}
+	if !sleepUntil(ctx, deadline) {
+		return nil, false
+	}
+
+	now := time.Now()
+	var due []CANMessage
+	for i := range s.entries {
+		e := &s.entries[i]
+		if e.next.IsZero() || e.next.After(now) {
+			continue
+		}
+		due = append(due, e.msg)
+		if e.msg.TxType == txOnChange {
+			e.next = time.Time{}
+			continue
+		}
+
+		// Stay on the original time grid; if we fell more than a whole
+		// interval behind, skip the missed slots instead of bursting
+		interval := e.msg.interval()
+		e.next = e.next.Add(interval)
+		if !e.next.After(now) {
+			e.next = e.next.Add((now.Sub(e.next)/interval + 1) * interval)
+		}
+	}
+	return due, true
+}
+
+// withEvents records the values of the due messages and adds any
+// event-driven message whose value has changed since it was last sent:
+// for a physical-value message by more than its ChangeThreshold, for any
+// other message by any change to its payload. With a coalesceWindow a
+// change is held until the window closes and dropped if the value changes
+// back or the message is sent on its cycle in the meantime.
+func (s *txScheduler) withEvents(due []CANMessage, state vehicleModel) []CANMessage {
+	if !s.events {
+		return due
+	}
+	now := time.Now()
+	var fired []CANMessage
+	for i := range s.entries {
+		e := &s.entries[i]
+		if e.msg.TxType == txCyclic {
+			continue
+		}
+		var changed bool
+		var value float64
+		var data [8]byte
+		if e.msg.Value != nil {
+			value = e.msg.Value(state)
+			changed = math.Abs(value-e.value) > e.msg.ChangeThreshold
+		} else if e.msg.Encode != nil {
+			data = e.msg.Encode(state)
+			changed = data != e.data
+		}
+
+		switch {
+		case slices.ContainsFunc(due, func(m CANMessage) bool { return m.ID == e.msg.ID }):
+			e.hasEvent, e.value, e.data = true, value, data
+			e.pending = time.Time{}
+		case !e.hasEvent || !changed:
+			e.pending = time.Time{}
+		case coalesceWindow > 0 && e.pending.IsZero():
+			e.pending = now
+		case coalesceWindow == 0 || !now.Before(e.pending.Add(coalesceWindow)):
+			fired = append(fired, e.msg)
+			e.value, e.data = value, data
+			e.pending = time.Time{}
+		}
+	}
+	if len(fired) == 0 {
+		return due
+	}
+	due = append(due, fired...)
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	return due
+}
+
+// sleepUntil blocks until deadline, sleeping until shortly before it and
+// spinning the rest. It returns false if ctx is cancelled first.
+func sleepUntil(ctx context.Context, deadline time.Time) bool {
+	if d := time.Until(deadline) - spinThreshold; d > 0 {
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+	for time.Now().Before(deadline) {
+		if ctx.Err() != nil {
+			return false
+		}
+		runtime.Gosched()
+	}
+	return ctx.Err() == nil
+}
+
+// applyIntervals sets transmit intervals from a -interval value like
+// "EngineRPM=1ms,ThrottlePosition=10ms".
+func applyIntervals(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return fmt.Errorf("invalid interval %q, expected Name=duration", item)
+		}
+		msg, found := MessageByName(name)
+		if !found {
+			return fmt.Errorf("unknown message %q", name)
+		}
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("invalid interval %q for %s", value, name)
+		}
+		msg.Interval = interval
+		updateMessage(msg)
+	}
+	return nil
+}
+
+// applyTxTypes sets transmit types from a -tx-type value like
+// "EngineRPM=cyclic+event:100,KeyPosition=onchange". The optional
+// threshold is the change in physical value that fires an event.
+func applyTxTypes(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return fmt.Errorf("invalid transmit type %q, expected Name=type[:threshold]", item)
+		}
+		msg, found := MessageByName(name)
+		if !found {
+			return fmt.Errorf("unknown message %q", name)
+		}
+		if msg.Encode == nil && msg.Value == nil {
+			return fmt.Errorf("cannot set the transmit type of %s: not simulated", name)
+		}
+		typeName, threshold, hasThreshold := strings.Cut(value, ":")
+		t, ok := txTypeNames[typeName]
+		if !ok {
+			return fmt.Errorf("invalid transmit type %q for %s, expected cyclic, onchange or cyclic+event", typeName, name)
+		}
+		msg.TxType = t
+		if hasThreshold {
+			if msg.Value == nil {
+				return fmt.Errorf("%s has no physical value to apply a change threshold to", name)
+			}
+			th, err := strconv.ParseFloat(threshold, 64)
+			if err != nil || th < 0 {
+				return fmt.Errorf("invalid change threshold %q for %s", threshold, name)
+			}
+			msg.ChangeThreshold = th
+		}
+		updateMessage(msg)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// bitSignal is a field packed at bit granularity into a payload. Bits are
+// numbered LSB-first within each byte from byte 0, so bit 0 is the low bit
+// of data[0] and bit 9 is bit 1 of data[1].
+//
+// Intel (little-endian) signals start at their least significant bit and
+// count upwards. Motorola (BigEndian) signals start at their most
+// significant bit and count down through each byte before moving on to the
+// high bit of the next, as in a DBC file.
+type bitSignal struct {
+	Name      string
+	StartBit  uint8
+	Length    uint8
+	BigEndian bool
+	Signed    bool
+	Factor    float64 // Physical scaling, unscaled if zero
+	Offset    float64
+	Unit      string
+	Comment   string
+}
+
+// bits returns the payload bit positions of the signal, least significant
+// first.
+func (s bitSignal) bits() []int {
+	bits := make([]int, s.Length)
+	pos := int(s.StartBit)
+	for i := range bits {
+		if s.BigEndian {
+			bits[len(bits)-1-i] = pos
+			if pos%8 == 0 {
+				pos += 15
+			} else {
+				pos--
+			}
+		} else {
+			bits[i] = pos
+			pos++
+		}
+	}
+	return bits
+}
+
+// extract reads the signal's raw value from data. Bits beyond the end of
+// data read as zero.
+func (s bitSignal) extract(data []byte) uint64 {
+	var value uint64
+	for i, bit := range s.bits() {
+		if bit/8 < len(data) && data[bit/8]&(1<<(bit%8)) != 0 {
+			value |= 1 << i
+		}
+	}
+	return value
+}
+
+// insert writes value into the signal's bits of data, leaving the rest of
+// the payload untouched. Bits of value wider than the signal are dropped.
+func (s bitSignal) insert(data *[8]byte, value uint64) {
+	for i, bit := range s.bits() {
+		if bit/8 >= len(data) {
+			continue
+		}
+		if value&(1<<i) != 0 {
+			data[bit/8] |= 1 << (bit % 8)
+		} else {
+			data[bit/8] &^= 1 << (bit % 8)
+		}
+	}
+}
+
+// physical extracts the scaled physical value of the signal.
+func (s bitSignal) physical(data []byte) float64 {
+	raw := s.extract(data)
+	factor := s.Factor
+	if factor == 0 {
+		factor = 1
+	}
+	if s.Signed && s.Length > 0 && s.Length < 64 && raw&(1<<(s.Length-1)) != 0 {
+		return float64(int64(raw)-int64(1)<<s.Length)*factor + s.Offset
+	}
+	return float64(raw)*factor + s.Offset
+}
+
+// decodeSignals returns a decoder that prints every signal of a message
+// with its unit, for messages imported without a hand-written decoder.
+func decodeSignals(name string, signals []bitSignal) func(data []byte) string {
+	return func(data []byte) string {
+		parts := make([]string, len(signals))
+		for i, sig := range signals {
+			parts[i] = sig.Name + "=" + strconv.FormatFloat(sig.physical(data), 'g', -1, 64)
+			if sig.Unit != "" {
+				parts[i] += " " + sig.Unit
+			}
+		}
+		return fmt.Sprintf("%s: %s", name, strings.Join(parts, ", "))
+	}
+}
+
+// encodeModelSignals returns an encoder for imported cyclic messages. Each
+// signal named after a model reading, as listed by sensorValues, carries
+// the current value; the others, and values the signal cannot represent,
+// are sent as raw zero.
+func encodeModelSignals(signals []bitSignal) func(v vehicleModel) [8]byte {
+	return func(v vehicleModel) [8]byte {
+		var data [8]byte
+		values := v.sensorValues()
+		for _, sig := range signals {
+			value, ok := values[sig.Name]
+			if !ok {
+				continue
+			}
+			if raw, err := sig.rawValue(value); err == nil {
+				sig.insert(&data, raw)
+			}
+		}
+		return data
+	}
+}
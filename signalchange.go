@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// SignalChange is published when a received physical value moves by more
+// than the notifier's delta. Name is the message name for single-value
+// messages and "Message.Signal" for messages with signals.
+type SignalChange struct {
+	Name     string
+	Old, New float64
+	Time     time.Time
+}
+
+// changeNotifier is a sink that decodes the physical values of received
+// frames and publishes their changes to subscribers. Sends never block: a
+// subscriber whose buffer is full misses the event, which is counted.
+type changeNotifier struct {
+	delta float64 // Minimum change published, set with -change-delta
+	last  map[string]float64
+
+	mu          sync.Mutex
+	subscribers map[chan SignalChange]*uint64 // Events dropped per subscriber
+}
+
+// signalChanges publishes the changes seen by the receive loop.
+var signalChanges = &changeNotifier{last: make(map[string]float64), subscribers: make(map[chan SignalChange]*uint64)}
+
+// SubscribeSignalChanges returns a channel of signal changes buffered to
+// buffer events, and a function that ends the subscription and closes the
+// channel. Events that arrive while the buffer is full are dropped.
+func SubscribeSignalChanges(buffer int) (<-chan SignalChange, func()) {
+	return signalChanges.subscribe(buffer)
+}
+
+func (n *changeNotifier) subscribe(buffer int) (<-chan SignalChange, func()) {
+	ch := make(chan SignalChange, buffer)
+	n.mu.Lock()
+	n.subscribers[ch] = new(uint64)
+	n.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			n.mu.Lock()
+			dropped := *n.subscribers[ch]
+			delete(n.subscribers, ch)
+			n.mu.Unlock()
+			close(ch)
+			if dropped > 0 {
+				log.Printf("Signal change subscriber missed %d events while its buffer was full", dropped)
+			}
+		})
+	}
+}
+
+// publish sends a change to every subscriber that has room for it.
+func (n *changeNotifier) publish(change SignalChange) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch, dropped := range n.subscribers {
+		select {
+		case ch <- change:
+		default:
+			*dropped++
+		}
+	}
+}
+
+// OnReceive compares the physical values of a decoded frame with the last
+// ones seen. The first value of each signal only sets the baseline.
+func (n *changeNotifier) OnReceive(frame can.Frame, decoded string) {
+	if decoded == "" || frame.IsExtended {
+		return
+	}
+	msg, ok := CAN_DBC[frame.ID]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	data := frame.Data[:frame.Length]
+	for name, value := range msg.physicalValues(data) {
+		old, seen := n.last[name]
+		if seen && math.Abs(value-old) <= n.delta {
+			continue
+		}
+		n.last[name] = value
+		if seen {
+			n.publish(SignalChange{Name: name, Old: old, New: value, Time: now})
+		}
+	}
+}
+
+func (n *changeNotifier) OnTransmit(frame can.Frame) {}
+
+// physicalValues extracts every physical value a payload carries, keyed as
+// in SignalChange. Messages decoded only by a hand-written Decode have none.
+func (m CANMessage) physicalValues(data []byte) map[string]float64 {
+	values := make(map[string]float64, len(m.Signals)+1)
+	if m.Value != nil && len(data) >= int(m.ValueLen) {
+		values[m.Name] = m.physical(data)
+	}
+	for _, sig := range m.Signals {
+		values[m.Name+"."+sig.Name] = sig.physical(data)
+	}
+	return values
+}
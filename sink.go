@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// Sink is an output for bus traffic. OnReceive is called from the receive
+// loop for every received frame, after any command it carries has been
+// handled, with its decoded text or "" if it could not be decoded.
+// OnTransmit is called for every frame the simulator transmits, from the
+// transmitting goroutine. Sinks are called synchronously and must not
+// block.
+type Sink interface {
+	OnReceive(frame can.Frame, decoded string)
+	OnTransmit(frame can.Frame)
+}
+
+// sinks are the registered outputs. They are added at startup, before any
+// frame is received or transmitted, and not changed afterwards.
+var sinks []Sink
+
+// addSink registers an output.
+func addSink(s Sink) {
+	sinks = append(sinks, s)
+}
+
+// notifyReceive passes a received frame to every sink.
+func notifyReceive(frame can.Frame, decoded string) {
+	for _, s := range sinks {
+		s.OnReceive(frame, decoded)
+	}
+}
+
+// notifyTransmit passes a transmitted frame to every sink. It matches the
+// socketcan.FrameInterceptor signature so it can hook into a transmitter.
+func notifyTransmit(frame can.Frame) {
+	for _, s := range sinks {
+		s.OnTransmit(frame)
+	}
+}
+
+// sinkEvent is a received frame queued for the sinks of a sinkQueue.
+type sinkEvent struct {
+	frame   can.Frame
+	decoded string
+}
+
+// sinkQueue is a sink that hands frames to slower sinks through a bounded
+// buffer, so that a sink waiting on I/O cannot stall the receive loop or a
+// transmitter. When the buffer is full the oldest frame is dropped and
+// counted in the stats. The queued sinks are called from a single
+// goroutine, in order.
+type sinkQueue struct {
+	events chan sinkEvent
+	sinks  []Sink
+	done   chan struct{} // Closed once run has drained the buffer
+}
+
+func newSinkQueue(size int) *sinkQueue {
+	return &sinkQueue{events: make(chan sinkEvent, size), done: make(chan struct{})}
+}
+
+// add registers a sink behind the queue. Like addSink, it must be called
+// before run.
+func (q *sinkQueue) add(s Sink) {
+	q.sinks = append(q.sinks, s)
+}
+
+// enqueue buffers an event, making room by dropping the oldest.
+func (q *sinkQueue) enqueue(ev sinkEvent) {
+	for {
+		select {
+		case q.events <- ev:
+			return
+		default:
+		}
+		select {
+		case <-q.events:
+			stats.recordSinkDrop()
+		default:
+		}
+	}
+}
+
+func (q *sinkQueue) OnReceive(frame can.Frame, decoded string) {
+	q.enqueue(sinkEvent{frame: frame, decoded: decoded})
+}
+
+// OnTransmit queues nothing: none of the queued sinks handle transmitted
+// frames, and under load they would push received ones out of the buffer.
+func (q *sinkQueue) OnTransmit(frame can.Frame) {}
+
+// run dispatches queued frames until ctx is cancelled, then dispatches
+// whatever is still buffered so the last frames before shutdown are not
+// lost, and closes done.
+func (q *sinkQueue) run(ctx context.Context) {
+	defer close(q.done)
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case ev := <-q.events:
+					q.dispatch(ev)
+				default:
+					return
+				}
+			}
+		case ev := <-q.events:
+			q.dispatch(ev)
+		}
+	}
+}
+
+// dispatch hands one event to every queued sink.
+func (q *sinkQueue) dispatch(ev sinkEvent) {
+	for _, s := range q.sinks {
+		s.OnReceive(ev.frame, ev.decoded)
+	}
+}
+
+// wait blocks until run has returned, having drained the buffer.
+func (q *sinkQueue) wait() {
+	<-q.done
+}
+
+// logSink writes received frames to the log, one line per frame, with
+// the parts chosen by its log mode.
+type logSink struct {
+	mode logMode
+}
+
+func (s logSink) OnReceive(frame can.Frame, decoded string) {
+	data := frame.Data[:frame.Length]
+	switch {
+	case decoded == "" || s.mode == logRaw:
+		log.Printf("%03x		[%d]	%v		'%s'", frame.ID, frame.Length, frame.Data, data)
+	case s.mode == logDecoded && frame.IsExtended:
+		log.Printf("%08x	%s", frame.ID, decoded)
+	case s.mode == logDecoded:
+		log.Printf("%03x		%s", frame.ID, decoded)
+	case frame.IsExtended:
+		log.Printf("%08x	[%d]	%v		%s", frame.ID, frame.Length, frame.Data, decoded)
+	default:
+		log.Printf("%03x		[%d]	%v		'%s'	'%s'", frame.ID, frame.Length, frame.Data, data, decoded)
+	}
+}
+
+func (logSink) OnTransmit(frame can.Frame) {}
+
+// OnReceive counts a received frame.
+func (s *busStats) OnReceive(frame can.Frame, decoded string) {
+	s.recordReceive(frame)
+}
+
+// OnTransmit counts a successfully transmitted frame.
+func (s *busStats) OnTransmit(frame can.Frame) {
+	s.recordTransmit(frame)
+}
+
+// OnReceive records a received frame in the history.
+func (r *frameRing) OnReceive(frame can.Frame, decoded string) {
+	r.add(frame, time.Now())
+}
+
+func (r *frameRing) OnTransmit(frame can.Frame) {}
+
+// mirrorSink retransmits received frames through a frameMirror.
+type mirrorSink struct {
+	ctx    context.Context
+	mirror *frameMirror
+}
+
+func (s mirrorSink) OnReceive(frame can.Frame, decoded string) {
+	if err := s.mirror.forward(s.ctx, frame); err != nil {
+		log.Printf("failed to mirror frame ID 0x%x: %v", frame.ID, err)
+	}
+}
+
+func (s mirrorSink) OnTransmit(frame can.Frame) {}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// savedState is the accumulated state persisted with -state-file, so a
+// long run survives a crash or restart. Readings that the model refreshes
+// on every tick, such as the fuel level, are not saved. Neither is any
+// mileage: the model has no vehicle speed, so engine hours are the only
+// usage it accumulates.
+type savedState struct {
+	SavedAt      time.Time     `json:"saved_at"`
+	EngineHours  float64       `json:"engine_hours"`
+	StoredDTCs   []storedDTC   `json:"stored_dtcs"`
+	InjectedDTCs []injectedDTC `json:"injected_dtcs"`
+}
+
+// SaveState writes the accumulated state to path. The file is replaced
+// atomically, so a crash while saving leaves the previous snapshot intact,
+// and is readable by all like any other file the simulator writes.
+func SaveState(path string) error {
+	simulationMux.Lock()
+	state := savedState{
+		SavedAt:      time.Now(),
+		EngineHours:  vehicle.engineHours.Hours(),
+		StoredDTCs:   dtcs.list(),
+		InjectedDTCs: dtcs.listInjected(),
+	}
+	simulationMux.Unlock()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	// CreateTemp makes the file private, and the rename would carry that
+	// over to the snapshot
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadState restores the accumulated state saved at path. A missing or
+// unreadable snapshot is not an error: the run starts fresh with a
+// warning.
+func LoadState(path string) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("Warning: no saved state at %s, starting fresh", path)
+		return
+	}
+	var state savedState
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	if err != nil {
+		log.Printf("Warning: ignoring corrupt saved state %s, starting fresh: %v", path, err)
+		return
+	}
+
+	simulationMux.Lock()
+	vehicle.engineHours = time.Duration(state.EngineHours * float64(time.Hour))
+	for _, dtc := range state.StoredDTCs {
+		dtcs.stored[dtc.Code] = dtc.SetAt
+	}
+	for _, dtc := range state.InjectedDTCs {
+		if code, err := parseDTC(dtc.Code); err == nil {
+			dtcs.injected[code] = dtc.SetAt
+		}
+	}
+	simulationMux.Unlock()
+	log.Printf("Restored state saved at %s: %.1f engine hours, %d DTCs", state.SavedAt.Format(time.RFC3339), state.EngineHours, len(state.StoredDTCs)+len(state.InjectedDTCs))
+}
+
+// saveStatePeriodically saves the state every interval until ctx is
+// cancelled. The final save on shutdown is left to the caller.
+func saveStatePeriodically(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := SaveState(path); err != nil {
+			log.Printf("failed to save state to %s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveStateRoundTrip(t *testing.T) {
+	savedVehicle := vehicle
+	t.Cleanup(func() { vehicle = savedVehicle })
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	vehicle.engineHours = 90 * time.Minute
+	if err := SaveState(path); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o644 {
+		t.Errorf("state file mode = %v, want %v", mode, os.FileMode(0o644))
+	}
+
+	vehicle.engineHours = 0
+	LoadState(path)
+	if vehicle.engineHours != 90*time.Minute {
+		t.Errorf("restored engine hours = %v, want %v", vehicle.engineHours, 90*time.Minute)
+	}
+}
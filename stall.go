@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// The engine stalls when its speed stays below stallRPM for stallGrace,
+// set with -stall-rpm and -stall-grace. The speed checked is the one being
+// transmitted, so forcing EngineRPM low through an override stalls it. A
+// dip shorter than the grace period, as in a gear shift, does not. Zero
+// stallRPM disables stalling.
+var (
+	stallRPM   = 0
+	stallGrace = 500 * time.Millisecond
+)
+
+// stallDetector debounces low engine speed. It is owned by the sensor
+// simulation loop.
+type stallDetector struct {
+	below time.Time // When the speed fell below stallRPM, zero if it is above
+}
+
+// check reports whether the engine has now been below stallRPM for the
+// whole grace period. Speed is not checked during the gauge sweep.
+func (d *stallDetector) check(state vehicleModel, now time.Time) bool {
+	if stallRPM <= 0 || state.sweeping() || state.engineRPM >= stallRPM {
+		d.below = time.Time{}
+		return false
+	}
+	if d.below.IsZero() {
+		d.below = now
+	}
+	return now.Sub(d.below) >= stallGrace
+}
+
+// stallEngine stops a running engine as a stall: the engine winds down as
+// when switched off, but the ignition stays where it is, so the engine
+// can be restarted by cranking.
+func stallEngine(rpm int) {
+	simulationMux.Lock()
+	defer simulationMux.Unlock()
+	if !engineOn {
+		return
+	}
+	engineOn = false
+	vehicle.engineStopped(modelNow())
+	vehicle.stalled = true
+	log.Printf("Engine stalled: below %d rpm (at %d rpm) for %s", stallRPM, rpm, stallGrace)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// idStats holds the traffic counters for a single CAN ID.
+type idStats struct {
+	Name           string    `json:"name,omitempty"`
+	Transmitted    uint64    `json:"transmitted"`
+	TransmitErrors uint64    `json:"transmit_errors"`
+	Dropped        uint64    `json:"dropped"`   // Frames withheld by -loss
+	Throttled      uint64    `json:"throttled"` // Frames held back by -tx-rate
+	Received       uint64    `json:"received"`
+	DecodeErrors   uint64    `json:"decode_errors"`
+	Lost           uint64    `json:"lost"`       // Frames missing according to the rolling counter
+	Duplicates     uint64    `json:"duplicates"` // Identical frames received back to back
+	LastSeen       time.Time `json:"last_seen,omitempty"`
+}
+
+// busStats collects per-ID traffic counters for the shutdown summary and
+// the HTTP API.
+type busStats struct {
+	mu          sync.Mutex
+	started     time.Time
+	ids         map[uint32]*idStats
+	diagLatency latencyHistogram // Diagnostic requests, from receipt to response sent
+	rxOverflows uint64           // Receive buffer overflows reported by the controller
+	sinkDrops   uint64           // Frames dropped by a full sink queue
+}
+
+// stats is the process-wide traffic statistics collector.
+var stats = &busStats{started: time.Now(), ids: make(map[uint32]*idStats)}
+
+// entry returns the counters for id, creating them on first use. The caller
+// must hold s.mu.
+func (s *busStats) entry(id uint32) *idStats {
+	e, ok := s.ids[id]
+	if !ok {
+		e = &idStats{Name: CAN_DBC[id].Name}
+		s.ids[id] = e
+	}
+	return e
+}
+
+// recordTransmit counts a successfully transmitted frame.
+func (s *busStats) recordTransmit(frame can.Frame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(frame.ID).Transmitted++
+}
+
+// recordDrop counts a frame randomly dropped instead of transmitted.
+func (s *busStats) recordDrop(id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(id).Dropped++
+}
+
+// recordThrottle counts a frame not sent because the transmit rate ceiling
+// was reached.
+func (s *busStats) recordThrottle(id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(id).Throttled++
+}
+
+// recordTransmitError counts a frame that could not be transmitted.
+func (s *busStats) recordTransmitError(id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(id).TransmitErrors++
+}
+
+// recordReceive counts a received frame and updates its last-seen time.
+func (s *busStats) recordReceive(frame can.Frame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entry(frame.ID)
+	e.Received++
+	e.LastSeen = time.Now()
+}
+
+// recordDecodeError counts a received frame that could not be decoded.
+func (s *busStats) recordDecodeError(id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(id).DecodeErrors++
+}
+
+// recordLost counts frames found missing from a rolling counter sequence.
+func (s *busStats) recordLost(id uint32, n uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(id).Lost += n
+}
+
+// recordDuplicate counts a suspected double-sent frame.
+func (s *busStats) recordDuplicate(id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(id).Duplicates++
+}
+
+// recordRxOverflow counts a receive buffer overflow and returns the
+// overflows so far together with the frames known lost across all IDs.
+func (s *busStats) recordRxOverflow() (overflows, lost uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rxOverflows++
+	for _, e := range s.ids {
+		lost += e.Lost
+	}
+	return s.rxOverflows, lost
+}
+
+// recordSinkDrop counts a frame dropped before reaching the queued sinks.
+func (s *busStats) recordSinkDrop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sinkDrops++
+}
+
+// droppedBySinks returns how many frames the sink queue dropped.
+func (s *busStats) droppedBySinks() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sinkDrops
+}
+
+// overflows returns how many receive buffer overflows were reported.
+func (s *busStats) overflows() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rxOverflows
+}
+
+// recordDiagLatency adds a completed diagnostic request/response pair.
+func (s *busStats) recordDiagLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.diagLatency.add(d)
+}
+
+// diagLatencySummary reports the diagnostic latency distribution, or nil if
+// no request has completed.
+func (s *busStats) diagLatencySummary() *latencySummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.diagLatency.summary()
+}
+
+// snapshot returns a copy of the counters keyed by CAN ID.
+func (s *busStats) snapshot() map[uint32]idStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[uint32]idStats, len(s.ids))
+	for id, e := range s.ids {
+		out[id] = *e
+	}
+	return out
+}
+
+// uptime returns how long statistics have been collected.
+func (s *busStats) uptime() time.Duration {
+	return time.Since(s.started)
+}
+
+// sortedIDs returns the keys of a snapshot in ascending order.
+func sortedIDs(snap map[uint32]idStats) []uint32 {
+	ids := make([]uint32, 0, len(snap))
+	for id := range snap {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// topRates is how many messages the busiest-message rankings list, set
+// with -top.
+var topRates = 5
+
+// messageRate is a message ranked by its average frame rate over the run.
+type messageRate struct {
+	ID   string  `json:"id"`
+	Name string  `json:"name,omitempty"`
+	Rate float64 `json:"rate_hz"`
+}
+
+// busiest ranks the IDs of a snapshot by the frames per second count
+// gives over uptime, highest first and at most topRates of them. IDs with
+// no frames are left out.
+func busiest(snap map[uint32]idStats, uptime time.Duration, count func(idStats) uint64) []messageRate {
+	var ranked []messageRate
+	for _, id := range sortedIDs(snap) {
+		if n := count(snap[id]); n > 0 {
+			ranked = append(ranked, messageRate{ID: formatID(id), Name: snap[id].Name, Rate: float64(n) / uptime.Seconds()})
+		}
+	}
+	// Stable, so equal rates stay in ID order
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Rate > ranked[j].Rate })
+	return ranked[:min(len(ranked), topRates)]
+}
+
+func receivedFrames(e idStats) uint64    { return e.Received }
+func transmittedFrames(e idStats) uint64 { return e.Transmitted }
+
+// formatRates renders a ranking for the log.
+func formatRates(ranked []messageRate) string {
+	parts := make([]string, len(ranked))
+	for i, r := range ranked {
+		parts[i] = fmt.Sprintf("%s %s %.1f/s", r.ID, r.Name, r.Rate)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// logSummary writes the per-ID counters to the log, used on shutdown.
+func (s *busStats) logSummary() {
+	snap := s.snapshot()
+	log.Printf("Traffic summary after %s:", s.uptime().Round(time.Second))
+	for _, id := range sortedIDs(snap) {
+		e := snap[id]
+		lastSeen := "never"
+		if !e.LastSeen.IsZero() {
+			lastSeen = e.LastSeen.Format(time.RFC3339)
+		}
+		log.Printf("%03x	%-20s	tx=%d	tx_errors=%d	dropped=%d	throttled=%d	rx=%d	decode_errors=%d	lost=%d	duplicates=%d	last_seen=%s", id, e.Name, e.Transmitted, e.TransmitErrors, e.Dropped, e.Throttled, e.Received, e.DecodeErrors, e.Lost, e.Duplicates, lastSeen)
+	}
+	if top := busiest(snap, s.uptime(), receivedFrames); len(top) > 0 {
+		log.Printf("Busiest received: %s", formatRates(top))
+	}
+	if top := busiest(snap, s.uptime(), transmittedFrames); len(top) > 0 {
+		log.Printf("Busiest transmitted: %s", formatRates(top))
+	}
+	if n := s.overflows(); n > 0 {
+		log.Printf("Receive buffer overflowed %d times; decoded output is incomplete", n)
+	}
+	if n := s.droppedBySinks(); n > 0 {
+		log.Printf("Sink queue was full %d times; logged and mirrored output is incomplete", n)
+	}
+	if l := s.diagLatencySummary(); l != nil {
+		log.Printf("Diagnostic latency over %d requests: min=%.2fms avg=%.2fms p95=%.2fms max=%.2fms", l.Count, l.MinMs, l.AvgMs, l.P95Ms, l.MaxMs)
+	}
+}
+
+// formatID renders a CAN ID the way the API keys messages.
+func formatID(id uint32) string {
+	return fmt.Sprintf("0x%03x", id)
+}
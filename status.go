@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// vehicleStatusSignals are the flag bits of the VehicleStatus message. The
+// flags are set independently, by the 0x107 StatusCommand frame or the
+// API, and broadcast whatever the engine state.
+var vehicleStatusSignals = []bitSignal{
+	{Name: "DoorOpen", StartBit: 0, Length: 1, Comment: "Any door open"},
+	{Name: "SeatbeltUnfastened", StartBit: 1, Length: 1, Comment: "Driver seatbelt not fastened"},
+	{Name: "Handbrake", StartBit: 2, Length: 1, Comment: "Parking brake applied"},
+	{Name: "CheckEngine", StartBit: 3, Length: 1, Comment: "Check engine flag"},
+}
+
+// statusFlagSignal looks up a status flag by name, ignoring case.
+func statusFlagSignal(name string) (bitSignal, bool) {
+	for _, sig := range vehicleStatusSignals {
+		if strings.EqualFold(sig.Name, name) {
+			return sig, true
+		}
+	}
+	return bitSignal{}, false
+}
+
+// decodeVehicleStatus prints each flag carried by the VehicleStatus message.
+func decodeVehicleStatus(data []byte) string {
+	states := make([]string, len(vehicleStatusSignals))
+	for i, sig := range vehicleStatusSignals {
+		state := "OFF"
+		if sig.extract(data) == 1 {
+			state = "ON"
+		}
+		states[i] = sig.Name + " " + state
+	}
+	return "Vehicle Status: " + strings.Join(states, ", ")
+}
+
+func encodeVehicleStatus(v vehicleModel) [8]byte {
+	return v.statusFlags
+}
+
+// decodeStatusCommand prints a StatusCommand: byte 0 selects the flags to
+// change, byte 1 holds their new values, bit for bit as in VehicleStatus.
+func decodeStatusCommand(data []byte) string {
+	var changes []string
+	for _, sig := range vehicleStatusSignals {
+		if sig.extract(data[:1]) == 0 {
+			continue
+		}
+		state := "OFF"
+		if sig.extract(data[1:2]) == 1 {
+			state = "ON"
+		}
+		changes = append(changes, sig.Name+" "+state)
+	}
+	if changes == nil {
+		return "Status Command: no change"
+	}
+	return "Status Command: " + strings.Join(changes, ", ")
+}
+
+// setStatusFlags applies a received StatusCommand, changing only the flags
+// selected by the mask and leaving the others as they are.
+func setStatusFlags(mask, values byte) {
+	simulationMux.Lock()
+	defer simulationMux.Unlock()
+	flags := &vehicle.statusFlags[0]
+	*flags = *flags&^mask | values&mask
+}
+
+// setStatusFlag switches a single status flag.
+func setStatusFlag(name string, on bool) error {
+	sig, ok := statusFlagSignal(name)
+	if !ok {
+		return fmt.Errorf("unknown status flag %q", name)
+	}
+	var value uint64
+	if on {
+		value = 1
+	}
+	simulationMux.Lock()
+	defer simulationMux.Unlock()
+	sig.insert(&vehicle.statusFlags, value)
+	return nil
+}
+
+// statusFlagStates returns each status flag in the model, keyed by name.
+func (v vehicleModel) statusFlagStates() map[string]bool {
+	states := make(map[string]bool, len(vehicleStatusSignals))
+	for _, sig := range vehicleStatusSignals {
+		states[sig.Name] = sig.extract(v.statusFlags[:]) == 1
+	}
+	return states
+}
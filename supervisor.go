@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+// sensorRestartDelay is the pause before a panicked sensor simulation is
+// restarted, so a panic on every frame does not spin.
+const sensorRestartDelay = time.Second
+
+// superviseSensors runs simulateSensors, recovering from panics and
+// restarting it for as long as the engine is still on.
+func superviseSensors(ctx context.Context) {
+	for runSensorsRecovering(ctx) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(sensorRestartDelay):
+		}
+
+		simulationMux.Lock()
+		restart := engineOn
+		if !restart {
+			sensorLoopRunning = false
+		}
+		simulationMux.Unlock()
+		if !restart {
+			log.Println("Sensor simulation not restarted, the engine is off")
+			return
+		}
+		log.Println("Restarting sensor simulation")
+	}
+}
+
+// runSensorsRecovering runs simulateSensors and reports whether it ended in
+// a panic, logging the stack and leaving the loop count if so.
+func runSensorsRecovering(ctx context.Context) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("ALARM: sensor simulation panicked: %v\n%s", r, debug.Stack())
+			sensorLoops.Add(-1)
+			panicked = true
+		}
+	}()
+	simulateSensors(ctx)
+	return false
+}
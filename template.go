@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// templatePlaceholders resolves each ${name} of a payload template to a
+// byte of the current vehicle state. Temperatures carry a +40 °C offset so
+// the usual range fits an unsigned byte.
+var templatePlaceholders = map[string]func(v vehicleModel, counter uint8) byte{
+	"counter":  func(v vehicleModel, counter uint8) byte { return counter },
+	"rpm_hi":   func(v vehicleModel, counter uint8) byte { return byte(clampWord(v.engineRPM) >> 8) },
+	"rpm_lo":   func(v vehicleModel, counter uint8) byte { return byte(clampWord(v.engineRPM)) },
+	"temp":     func(v vehicleModel, counter uint8) byte { return clampByte(v.engineTemp + 40) },
+	"ambient":  func(v vehicleModel, counter uint8) byte { return clampByte(v.ambientTemp + 40) },
+	"throttle": func(v vehicleModel, counter uint8) byte { return clampByte(v.throttlePosition) },
+	"fuel":     func(v vehicleModel, counter uint8) byte { return clampByte(v.fuelTankLevel) },
+	"o2":       func(v vehicleModel, counter uint8) byte { return clampByte(v.oxygenSensor) },
+	"key":      func(v vehicleModel, counter uint8) byte { return byte(v.ignition) },
+}
+
+// clampByte limits a reading to the range of one unsigned byte.
+func clampByte(value int) byte {
+	return byte(min(max(value, 0), 0xFF))
+}
+
+// clampWord limits a reading to the range of two unsigned bytes before it
+// is split into them.
+func clampWord(value int) uint16 {
+	return uint16(min(max(value, 0), 0xFFFF))
+}
+
+// templateByte is one byte of a payload template: a literal, or the name
+// of a placeholder resolved at transmit time.
+type templateByte struct {
+	literal     byte
+	placeholder string
+}
+
+// payloadTemplate is a compiled payload such as
+// "AA ${counter} ${rpm_hi} ${rpm_lo}", one space-separated byte per slot.
+type payloadTemplate []templateByte
+
+// parsePayloadTemplate compiles a template, rejecting unknown placeholders.
+func parsePayloadTemplate(spec string) (payloadTemplate, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 8 {
+		return nil, fmt.Errorf("template %q must have 1-8 bytes", spec)
+	}
+	tmpl := make(payloadTemplate, len(fields))
+	for i, field := range fields {
+		if name, ok := strings.CutPrefix(field, "${"); ok && strings.HasSuffix(name, "}") {
+			name = strings.TrimSuffix(name, "}")
+			if _, known := templatePlaceholders[name]; !known {
+				return nil, fmt.Errorf("unknown placeholder ${%s}, available: %v", name, placeholderNames())
+			}
+			tmpl[i].placeholder = name
+			continue
+		}
+		b, err := strconv.ParseUint(field, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template byte %q", field)
+		}
+		tmpl[i].literal = byte(b)
+	}
+	return tmpl, nil
+}
+
+// placeholderNames returns the known placeholder names in sorted order.
+func placeholderNames() []string {
+	names := make([]string, 0, len(templatePlaceholders))
+	for name := range templatePlaceholders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// render substitutes the current state into the template.
+func (t payloadTemplate) render(v vehicleModel, counter uint8) [8]byte {
+	var data [8]byte
+	for i, b := range t {
+		if b.placeholder != "" {
+			data[i] = templatePlaceholders[b.placeholder](v, counter)
+		} else {
+			data[i] = b.literal
+		}
+	}
+	return data
+}
+
+// parseTemplateMessage parses a -template value of the form
+// "ID[:Name]=template" into a message transmitted with the engine running.
+func parseTemplateMessage(spec string) (CANMessage, error) {
+	head, body, ok := strings.Cut(spec, "=")
+	if !ok {
+		return CANMessage{}, fmt.Errorf("invalid template %q, expected ID[:Name]=bytes", spec)
+	}
+	idArg, name, _ := strings.Cut(head, ":")
+	id, err := strconv.ParseUint(idArg, 0, 11)
+	if err != nil {
+		return CANMessage{}, fmt.Errorf("invalid template ID %q", idArg)
+	}
+	if name == "" {
+		name = fmt.Sprintf("Template%03X", id)
+	}
+	tmpl, err := parsePayloadTemplate(body)
+	if err != nil {
+		return CANMessage{}, err
+	}
+	return CANMessage{ID: uint32(id), Name: name, DataLen: uint8(len(tmpl)), Decode: decodeRaw(name), Template: tmpl, RequiresEngine: true}, nil
+}
+
+// decodeRaw returns a decoder printing the payload as hex, for messages
+// without a signal layout.
+func decodeRaw(name string) func(data []byte) string {
+	return func(data []byte) string {
+		return fmt.Sprintf("%s: % x", name, data)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestPayloadTemplateRender(t *testing.T) {
+	tmpl, err := parsePayloadTemplate("AA ${counter} ${rpm_hi} ${rpm_lo} ${temp} ${ambient}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		v    vehicleModel
+		want [8]byte
+	}{
+		{
+			name: "in range",
+			v:    vehicleModel{engineRPM: 0x1234, engineTemp: 90, ambientTemp: 20},
+			want: [8]byte{0xAA, 5, 0x12, 0x34, 130, 60},
+		},
+		{
+			name: "clamped",
+			v:    vehicleModel{engineRPM: 70000, engineTemp: 300, ambientTemp: -50},
+			want: [8]byte{0xAA, 5, 0xFF, 0xFF, 0xFF, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tmpl.render(tt.v, 5); got != tt.want {
+				t.Errorf("render() = % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePayloadTemplateRejects(t *testing.T) {
+	for _, spec := range []string{"", "00 01 02 03 04 05 06 07 08", "${speed}", "GG"} {
+		if _, err := parsePayloadTemplate(spec); err == nil {
+			t.Errorf("parsePayloadTemplate(%q) succeeded, want an error", spec)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+	"time"
+
+	"go.einride.tech/can"
+	"go.einride.tech/can/pkg/socketcan"
+)
+
+const (
+	transmitAttempts     = 3                      // Tries per frame for retryable errors
+	transmitRetryBackoff = 2 * time.Millisecond   // First retry delay, doubled per attempt
+	reconnectBackoff     = 100 * time.Millisecond // First reconnect delay, doubled per attempt
+	maxReconnectBackoff  = 5 * time.Second
+)
+
+// frameSender is a connection frames can be transmitted on.
+type frameSender interface {
+	TransmitFrame(ctx context.Context, frame can.Frame) error
+	Close() error
+}
+
+// busDialer opens frame senders on a CAN interface. It is the seam that
+// lets the reconnect logic run without a real bus.
+type busDialer interface {
+	Dial(ctx context.Context, iface string) (frameSender, error)
+}
+
+// socketcanDialer dials SocketCAN, reporting every transmitted frame to
+// the sinks.
+type socketcanDialer struct{}
+
+func (socketcanDialer) Dial(ctx context.Context, iface string) (frameSender, error) {
+	conn, err := socketcan.DialContext(ctx, "can", iface)
+	if err != nil {
+		return nil, err
+	}
+	return socketcan.NewTransmitter(conn, socketcan.TransmitterFrameInterceptor(notifyTransmit)), nil
+}
+
+// busDial is the dialer transmitters are opened through. Tests replace it
+// to run the simulation loops without a bus.
+var busDial busDialer = socketcanDialer{}
+
+// retryAfter waits out a retry or reconnect backoff. Tests replace it to
+// record the delays instead of sleeping through them.
+var retryAfter = time.After
+
+// transmitConfirm is called after every attempt to hand a frame to the
+// bus, retries included, with its outcome and completion time.
+type transmitConfirm func(frame can.Frame, err error, t time.Time)
+
+// onTransmit, when set at startup, confirms every frame sent by the
+// simulation loops.
+var onTransmit transmitConfirm
+
+// logTransmit is the transmitConfirm for -log-tx.
+func logTransmit(frame can.Frame, err error, t time.Time) {
+	stamp := t.Format("15:04:05.000000")
+	if err != nil {
+		log.Printf("TX %03x	[%d]	%v	%s failed: %v", frame.ID, frame.Length, frame.Data, stamp, err)
+		return
+	}
+	log.Printf("TX %03x	[%d]	%v	%s", frame.ID, frame.Length, frame.Data, stamp)
+}
+
+// busTransmitter sends frames on a CAN interface. Transient failures such as
+// a full transmit queue are retried with backoff; any other failure drops
+// the connection and redials it.
+type busTransmitter struct {
+	iface  string
+	dialer busDialer
+	tx     frameSender
+
+	onTransmit transmitConfirm // Optional, see transmitConfirm
+}
+
+// dialTransmitter opens a transmitter on iface.
+func dialTransmitter(ctx context.Context, iface string) (*busTransmitter, error) {
+	return dialTransmitterWith(ctx, busDial, iface)
+}
+
+// dialTransmitterWith opens a transmitter on iface through dialer.
+func dialTransmitterWith(ctx context.Context, dialer busDialer, iface string) (*busTransmitter, error) {
+	b := &busTransmitter{iface: iface, dialer: dialer}
+	if err := b.dial(ctx); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *busTransmitter) dial(ctx context.Context) error {
+	tx, err := b.dialer.Dial(ctx, b.iface)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", b.iface, err)
+	}
+	b.tx = tx
+	return nil
+}
+
+// isRetryableTransmitError reports whether a transmit failure is transient.
+// ENOBUFS is what SocketCAN returns when the interface queue is full.
+func isRetryableTransmitError(err error) bool {
+	return errors.Is(err, syscall.ENOBUFS) || errors.Is(err, syscall.EAGAIN) || errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// errThrottled is returned for a frame held back by -tx-rate.
+var errThrottled = errors.New("transmit rate ceiling reached")
+
+// transmit sends frame, retrying transient errors. A frame over the
+// -tx-rate ceiling is counted as throttled and not sent; its counter has
+// already advanced, as it would for a frame the controller refused. A
+// frame that still cannot be sent is counted in the stats and logged; a
+// non-retryable error additionally triggers a reconnect.
+func (b *busTransmitter) transmit(ctx context.Context, frame can.Frame) error {
+	if txLimit != nil && !txLimit.allow(time.Now()) {
+		stats.recordThrottle(frame.ID)
+		return errThrottled
+	}
+
+	backoff := transmitRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := b.tx.TransmitFrame(ctx, frame)
+		if b.onTransmit != nil {
+			b.onTransmit(frame, err, time.Now())
+		}
+		if err == nil {
+			return nil
+		}
+
+		if !isRetryableTransmitError(err) {
+			stats.recordTransmitError(frame.ID)
+			log.Printf("failed to transmit frame ID 0x%x: %v, reconnecting to %s", frame.ID, err, b.iface)
+			b.reconnect(ctx)
+			return err
+		}
+		if attempt == transmitAttempts {
+			stats.recordTransmitError(frame.ID)
+			log.Printf("failed to transmit frame ID 0x%x after %d attempts: %v", frame.ID, attempt, err)
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-retryAfter(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// reconnect redials the interface with exponential backoff until it
+// succeeds or ctx is cancelled.
+func (b *busTransmitter) reconnect(ctx context.Context) {
+	b.tx.Close()
+
+	backoff := reconnectBackoff
+	for {
+		err := b.dial(ctx)
+		if err == nil {
+			log.Printf("Reconnected to %s", b.iface)
+			return
+		}
+		log.Printf("%v, retrying in %s", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-retryAfter(backoff):
+		}
+		backoff = min(backoff*2, maxReconnectBackoff)
+	}
+}
+
+// Close closes the underlying connection.
+func (b *busTransmitter) Close() error {
+	return b.tx.Close()
+}
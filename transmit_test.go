@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+// fakeSender is a frameSender that fails with its queued errors in turn,
+// then succeeds.
+type fakeSender struct {
+	mu       sync.Mutex
+	errs     []error
+	attempts int
+	sent     []can.Frame
+	closed   bool
+}
+
+func (s *fakeSender) TransmitFrame(_ context.Context, frame can.Frame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return err
+	}
+	s.sent = append(s.sent, frame)
+	return nil
+}
+
+func (s *fakeSender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// fakeDialer is a busDialer that fails with its queued errors in turn,
+// then hands out a new fakeSender per dial.
+type fakeDialer struct {
+	mu      sync.Mutex
+	errs    []error
+	dials   int
+	senders []*fakeSender
+}
+
+func (d *fakeDialer) Dial(context.Context, string) (frameSender, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dials++
+	if len(d.errs) > 0 {
+		err := d.errs[0]
+		d.errs = d.errs[1:]
+		return nil, err
+	}
+	s := &fakeSender{}
+	d.senders = append(d.senders, s)
+	return s, nil
+}
+
+// recordBackoffs replaces retryAfter for the test, returning the delays
+// waited for in order.
+func recordBackoffs(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		backoffs []time.Duration
+	)
+	saved := retryAfter
+	retryAfter = func(d time.Duration) <-chan time.Time {
+		mu.Lock()
+		backoffs = append(backoffs, d)
+		mu.Unlock()
+		c := make(chan time.Time, 1)
+		c <- time.Now()
+		return c
+	}
+	t.Cleanup(func() { retryAfter = saved })
+	return &backoffs
+}
+
+func TestTransmitRetriesFullQueue(t *testing.T) {
+	backoffs := recordBackoffs(t)
+	dialer := &fakeDialer{}
+	tx, err := dialTransmitterWith(context.Background(), dialer, "vcan0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := dialer.senders[0]
+	sender.errs = []error{syscall.ENOBUFS, syscall.ENOBUFS}
+
+	frame := can.Frame{ID: 0x123, Length: 1, Data: can.Data{0x42}}
+	if err := tx.transmit(context.Background(), frame); err != nil {
+		t.Fatalf("transmit: %v", err)
+	}
+	if sender.attempts != transmitAttempts {
+		t.Errorf("attempts = %d, want %d", sender.attempts, transmitAttempts)
+	}
+	if want := []time.Duration{2 * time.Millisecond, 4 * time.Millisecond}; !slices.Equal(*backoffs, want) {
+		t.Errorf("backoffs = %v, want %v", *backoffs, want)
+	}
+	if len(sender.sent) != 1 || sender.sent[0] != frame {
+		t.Errorf("sent = %v, want [%v]", sender.sent, frame)
+	}
+	if dialer.dials != 1 {
+		t.Errorf("dials = %d, want 1", dialer.dials)
+	}
+}
+
+func TestTransmitReconnectsAfterFatalError(t *testing.T) {
+	backoffs := recordBackoffs(t)
+	dialer := &fakeDialer{}
+	tx, err := dialTransmitterWith(context.Background(), dialer, "vcan0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := dialer.senders[0]
+	first.errs = []error{syscall.EIO}
+	down := errors.New("network is down")
+	dialer.errs = []error{down, down}
+
+	frame := can.Frame{ID: 0x123, Length: 1, Data: can.Data{0x42}}
+	if err := tx.transmit(context.Background(), frame); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("transmit error = %v, want %v", err, syscall.EIO)
+	}
+	if first.attempts != 1 {
+		t.Errorf("attempts = %d, want 1, fatal errors are not retried", first.attempts)
+	}
+	if !first.closed {
+		t.Error("failed connection was not closed")
+	}
+	// The initial dial, two failed redials and the one that succeeds
+	if dialer.dials != 4 {
+		t.Errorf("dials = %d, want 4", dialer.dials)
+	}
+	if want := []time.Duration{reconnectBackoff, 2 * reconnectBackoff}; !slices.Equal(*backoffs, want) {
+		t.Errorf("backoffs = %v, want %v", *backoffs, want)
+	}
+
+	if err := tx.transmit(context.Background(), frame); err != nil {
+		t.Fatalf("transmit after reconnect: %v", err)
+	}
+	if len(dialer.senders) != 2 {
+		t.Fatalf("senders = %d, want 2", len(dialer.senders))
+	}
+	if second := dialer.senders[1]; len(second.sent) != 1 || second.sent[0] != frame {
+		t.Errorf("sent after reconnect = %v, want [%v]", second.sent, frame)
+	}
+}
+
+func TestTransmitThrottledOverRate(t *testing.T) {
+	saved := txLimit
+	txLimit = newTokenBucket(1, 2)
+	t.Cleanup(func() { txLimit = saved })
+	dialer := &fakeDialer{}
+	tx, err := dialTransmitterWith(context.Background(), dialer, "vcan0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := can.Frame{ID: 0x7E8, Length: 1, Data: can.Data{0x42}}
+	for i := range 3 {
+		err := tx.transmit(context.Background(), frame)
+		if want := i == 2; errors.Is(err, errThrottled) != want {
+			t.Errorf("frame %d: transmit error = %v, throttled want %v", i+1, err, want)
+		}
+	}
+	if sent := len(dialer.senders[0].sent); sent != 2 {
+		t.Errorf("sent = %d, want the burst of 2", sent)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"go.einride.tech/can"
+)
+
+// runTx implements "vecu tx": read frames in candump format from stdin,
+// one per line, and transmit each as it is read until EOF. Lines that do
+// not parse are logged and skipped.
+func runTx(args []string) error {
+	fs := flag.NewFlagSet("tx", flag.ExitOnError)
+	iface := fs.String("iface", "vcan0", "CAN interface to transmit on")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	tx, err := dialTransmitter(ctx, *iface)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+
+	sent, skipped, err := transmitLines(ctx, tx, os.Stdin)
+	log.Printf("Sent %d frames on %s, skipped %d lines", sent, *iface, skipped)
+	return err
+}
+
+// transmitLines sends every frame read from r and returns how many were
+// sent and how many lines were skipped.
+func transmitLines(ctx context.Context, tx *busTransmitter, r io.Reader) (sent, skipped int, err error) {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		frame, err := parseCandumpLine(text)
+		if err != nil {
+			log.Printf("stdin line %d: %v, skipping", line, err)
+			skipped++
+			continue
+		}
+		if err := tx.transmit(ctx, frame); err != nil {
+			skipped++
+			continue
+		}
+		sent++
+	}
+	if err := scanner.Err(); err != nil {
+		return sent, skipped, fmt.Errorf("failed to read stdin: %w", err)
+	}
+	return sent, skipped, nil
+}
+
+// parseCandumpLine parses a frame in candump's compact "200#0064" form.
+// Lines from a candump log, "(1700000000.000000) vcan0 200#0064", are
+// accepted too: only the last field is used.
+func parseCandumpLine(text string) (can.Frame, error) {
+	fields := strings.Fields(text)
+	var frame can.Frame
+	if err := frame.UnmarshalString(fields[len(fields)-1]); err != nil {
+		return can.Frame{}, err
+	}
+	if err := frame.Validate(); err != nil {
+		return can.Frame{}, err
+	}
+	return frame, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"go.einride.tech/can"
+)
+
+// checkLoggedFrame reports what is wrong with a frame from a capture
+// against the message definitions, or "" if nothing is.
+func checkLoggedFrame(frame can.Frame) string {
+	msg, ok := MessageByID(frame.ID)
+	if !ok || frame.IsExtended {
+		return fmt.Sprintf("ID 0x%x is not in the DBC", frame.ID)
+	}
+	if frame.Length != msg.DataLen && !(padFrames && frame.Length == 8) {
+		return fmt.Sprintf("%s (0x%x) has DLC %d, expected %d", msg.Name, frame.ID, frame.Length, msg.DataLen)
+	}
+	if !msg.verifyChecksum(frame.Data[:frame.Length]) {
+		return fmt.Sprintf("%s (0x%x) fails its checksum", msg.Name, frame.ID)
+	}
+	return ""
+}
+
+// validateLog checks every frame read from r, logging each anomaly with
+// its line number, and returns how many frame lines were checked and how
+// many were anomalous.
+func validateLog(name string, r io.Reader) (frames, anomalies int, err error) {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		frames++
+		frame, err := parseCandumpLine(text)
+		if err != nil {
+			log.Printf("%s:%d: %v", name, line, err)
+			anomalies++
+			continue
+		}
+		if problem := checkLoggedFrame(frame); problem != "" {
+			log.Printf("%s:%d: %s", name, line, problem)
+			anomalies++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return frames, anomalies, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return frames, anomalies, nil
+}
+
+// runValidateLog implements "vecu validate-log": check a candump log
+// against the message definitions before replaying it, failing if any
+// line does not parse, has an unknown ID, the wrong DLC or a bad checksum.
+func runValidateLog(args []string) error {
+	fs := flag.NewFlagSet("validate-log", flag.ExitOnError)
+	dbcPath := fs.String("dbc", "", "also load message definitions from this DBC file")
+	aliasSpec := fs.String("alias", "", "rename imported DBC messages and signals as Old->New,...")
+	fs.BoolVar(&padFrames, "pad", false, "also accept frames padded to 8 bytes")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: validate-log [flags] <capture.log>")
+	}
+	var aliases map[string]string
+	if *aliasSpec != "" {
+		var err error
+		if aliases, err = parseAliases(*aliasSpec); err != nil {
+			return err
+		}
+	}
+	if err := loadMessages(*dbcPath, aliases); err != nil {
+		return err
+	}
+
+	path := fs.Arg(0)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	frames, anomalies, err := validateLog(path, f)
+	if err != nil {
+		return err
+	}
+	if anomalies > 0 {
+		return fmt.Errorf("%s: %d anomalies in %d frames", path, anomalies, frames)
+	}
+	log.Printf("%s: all %d frames valid", path, frames)
+	return nil
+}
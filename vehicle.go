@@ -0,0 +1,408 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	operatingTemp  = 80  // °C, lower bound of the normal operating range
+	closedLoopTemp = 40  // °C, O2 feedback control engages above this
+	warmUpRate     = 2.0 // °C per second while below operating temperature
+
+	// The reference engine the default tuning was built around. Other
+	// engines are scaled from it by displacement and inertia.
+	referenceDisplacement = 2.0 // Litres
+	referenceIdleRPM      = 800 // rpm with the throttle closed
+	referenceResponse     = 2.0 // Fraction of the gap to the target RPM closed per second
+
+	// A throttle opening faster than tipInRate is a tip-in. The transient
+	// enrichment it causes decays with time constant tipInDecay.
+	tipInRate  = 250.0 // % per second
+	tipInDecay = 300 * time.Millisecond
+
+	// modelTickInterval is how often the model advances, independent of
+	// how often each message is transmitted.
+	modelTickInterval = 100 * time.Millisecond
+)
+
+// redlineRPM is where the rev limiter cuts fuel, set with -redline.
+var redlineRPM = 7000
+
+// Idle hunting: the idle speed controller oscillates slowly around its
+// target, set with -idle-hunt and -idle-hunt-period.
+var (
+	idleHuntAmplitude = 40.0            // rpm
+	idleHuntPeriod    = 2 * time.Second // One full oscillation
+)
+
+// tipInEnrichment is the O2 reading spike at the peak of a tip-in, in
+// percentage points, set with -tip-in. The engine speed overshoots by 10
+// rpm per point.
+var tipInEnrichment = 15.0
+
+// coastDownTime is how long engine speed takes to fall to zero after the
+// engine is switched off, set with -coast-down. Zero stops it instantly.
+var coastDownTime = 1500 * time.Millisecond
+
+// gaugeSweepTime is the length of the gauge self-test on engine start, set
+// with -gauge-sweep: engine speed and fuel level sweep to full scale and
+// back before the simulation proper begins. Zero disables it.
+var gaugeSweepTime time.Duration
+
+// coolingRate is the fraction of the difference between engine and ambient
+// temperature lost per minute while the engine is off, set with
+// -cooling-rate.
+var coolingRate = 0.03
+
+// engineConfig describes the simulated engine, set with -displacement and
+// -inertia. A larger or heavier engine idles lower and its speed follows
+// the throttle more slowly.
+type engineConfig struct {
+	Displacement float64 // Litres
+	Inertia      float64 // Rotating mass relative to the reference engine
+}
+
+// engine is the active engine configuration.
+var engine = engineConfig{Displacement: referenceDisplacement, Inertia: 1}
+
+// idleRPM is the closed-throttle engine speed. It falls slowly with
+// displacement: about 1150 rpm at 0.6 l and 575 rpm at 6 l.
+func (e engineConfig) idleRPM() int {
+	return int(math.Round(referenceIdleRPM * math.Pow(referenceDisplacement/e.Displacement, 0.3)))
+}
+
+// response is the fraction of the gap to the target speed closed per
+// second, inversely proportional to inertia and slower for bigger engines.
+func (e engineConfig) response() float64 {
+	return referenceResponse / (e.Inertia * math.Sqrt(e.Displacement/referenceDisplacement))
+}
+
+// setEngineConfig validates and installs an engine configuration, moving
+// the idle point of throttleCurve to match.
+func setEngineConfig(e engineConfig) error {
+	if e.Displacement <= 0 || e.Inertia <= 0 {
+		return fmt.Errorf("invalid engine: displacement and inertia must be positive")
+	}
+	idle := e.idleRPM()
+	if idle >= throttleCurve[1].rpm {
+		return fmt.Errorf("invalid engine: %.2f l idles at %d rpm, above the part-throttle speed", e.Displacement, idle)
+	}
+	engine = e
+	throttleCurve[0].rpm = idle
+	return nil
+}
+
+// throttleCurve maps throttle position (%) to steady-state engine speed.
+// The 40-60% band matches the cruise range of 2500-3000 rpm, and full
+// throttle is past the default redline so the limiter can be reached.
+var throttleCurve = []struct{ throttle, rpm int }{
+	{0, referenceIdleRPM},
+	{40, 2500},
+	{60, 3000},
+	{100, 8000},
+}
+
+// vehicleModel holds the simulated vehicle state. Access is guarded by
+// simulationMux; the simulation works on copies taken under the lock.
+type vehicleModel struct {
+	engineHours    time.Duration // Total time spent with the engine running
+	ambientTemp    int           // °C
+	running        bool          // Engine switched on
+	stalled        bool          // Last stopped by a stall, until restarted
+	idle           bool          // Running with the throttle closed, held at idle speed
+	throttleClosed bool          // Driver's foot off the pedal
+	closedLoop     bool          // O2 feedback control active
+	fuelCut        bool          // Rev limiter is cutting fuel
+	limpHome       bool          // Degraded mode: speed capped, slow response
+	engineOffAt    time.Time     // When the engine was last switched off, zero if never
+	warmUpCarry    float64       // Fraction of a degree of warm-up not yet applied
+	coastLeft      time.Duration // Remaining coast-down after switch-off
+	coastFrom      int           // Engine speed when the coast-down began
+	huntTime       time.Duration // Time spent at idle, the phase of the idle hunt
+	lastThrottle   int           // Throttle position at the previous tick, %
+	tipIn          float64       // Remaining tip-in transient, 1 at its peak
+	sweepLeft      time.Duration // Remaining gauge sweep after engine start
+	rpmRate        float64       // Engine speed change over the last tick, rpm/s
+
+	activeFaults []uint8 // Plausibility fault codes currently violated
+	frontLights  [8]byte // FrontLight payload, one bit per lamp
+	statusFlags  [8]byte // VehicleStatus payload, one bit per flag
+
+	// Readings broadcast regardless of the engine state.
+	batteryVoltage float32 // V
+	ignition       IgnitionState
+
+	// Current sensor readings, refreshed on every tick while running.
+	engineTemp       int     // °C
+	injectorTiming   int     // ms
+	oxygenSensor     int     // %
+	fuelTankLevel    int     // %
+	throttlePosition int     // %
+	app1, app2       float64 // V, pedal sensors, see pedal.go
+	engineRPM        int     // rpm
+	massAirFlow      float32 // g/s
+}
+
+// vehicle is the vehicle model shared by the simulation and the receiver.
+// It starts fully warmed up so a plain engine start goes straight to the
+// normal operating range.
+var vehicle = vehicleModel{ambientTemp: 20, engineTemp: 90}
+
+// engineStopped records when the engine is switched off. Its temperature
+// is retained in engineTemp and cools from then on.
+func (v *vehicleModel) engineStopped(now time.Time) {
+	v.running, v.idle = false, false
+	v.engineOffAt = now
+	v.coastLeft = coastDownTime
+	v.coastFrom = v.engineRPM
+	v.fuelCut = false
+	v.sweepLeft = 0
+}
+
+// ResetState returns accumulated state to its initial values so test runs
+// can start afresh without restarting the process or cycling the engine.
+// Fuel level is re-read from the tank on every tick, so it needs no reset.
+// The caller must hold simulationMux.
+func (v *vehicleModel) ResetState() {
+	v.engineHours = 0
+	v.warmUpCarry = 0
+	v.activeFaults = nil
+}
+
+// coasting reports whether the engine is still spinning down after being
+// switched off.
+func (v vehicleModel) coasting() bool {
+	return v.coastLeft > 0
+}
+
+// tickCoast winds engine speed down linearly to zero over coastDownTime
+// with no fuel or air being metered.
+func (v *vehicleModel) tickCoast(dt time.Duration) {
+	v.coastLeft = max(0, v.coastLeft-dt)
+	v.engineRPM = int(float64(v.coastFrom) * float64(v.coastLeft) / float64(coastDownTime))
+	v.throttlePosition = 0
+	v.injectorTiming = 0
+	v.massAirFlow = 0
+}
+
+// engineStarted restores the engine temperature after an off period. The
+// retained temperature decays exponentially towards ambient, so a quick
+// restart is a warm start and a restart hours later is a cold one.
+func (v *vehicleModel) engineStarted(now time.Time) {
+	v.running, v.stalled = true, false
+	v.sweepLeft = gaugeSweepTime
+	if v.engineOffAt.IsZero() {
+		return
+	}
+	v.coastLeft = 0
+	decay := math.Exp(-coolingRate * now.Sub(v.engineOffAt).Minutes())
+	v.engineTemp = v.ambientTemp + int(math.Round(float64(v.engineTemp-v.ambientTemp)*decay))
+	v.engineOffAt = time.Time{}
+}
+
+// tick advances the model by dt. Engine hours and sensor readings only
+// change while the engine is running or coasting down.
+func (v *vehicleModel) tick(dt time.Duration, running bool) {
+	prevRPM := v.engineRPM
+	defer func() { v.rpmRate = float64(v.engineRPM-prevRPM) / dt.Seconds() }()
+
+	if !running {
+		if v.coasting() {
+			v.tickCoast(dt)
+		}
+		return
+	}
+	v.engineHours += dt
+	if v.sweeping() {
+		v.tickSweep(dt)
+		return
+	}
+
+	// Warm up towards the operating range, then fluctuate within it
+	if v.engineTemp < operatingTemp {
+		v.warmUpCarry += warmUpRate * dt.Seconds()
+		step := math.Floor(v.warmUpCarry)
+		v.engineTemp += int(step)
+		v.warmUpCarry -= step
+	} else {
+		v.engineTemp = fluctuate(80, 100) // Engine Temp: 80 - 100 °C
+	}
+
+	// The engine runs open loop (rich) until the O2 sensor is usable
+	v.closedLoop = v.engineTemp >= closedLoopTemp
+	if v.closedLoop {
+		v.oxygenSensor = fluctuate(90, 100) // Oxygen Sensor: 90 - 100%
+	} else {
+		v.oxygenSensor = fluctuate(70, 80) // Oxygen Sensor (open loop): 70 - 80%
+	}
+
+	// Generate fluctuating sensor values within defined ranges
+	v.injectorTiming = fluctuate(60, 90) // Injector Timing: 60 - 90 ms
+	v.fuelTankLevel = fluctuate(60, 80)  // Fuel Tank Level: 60 - 80%
+	if v.throttleClosed {
+		v.throttlePosition = 0 // Throttle Position: closed
+	} else {
+		v.throttlePosition = fluctuate(40, 60) // Throttle Position: 40 - 60%
+	}
+
+	// The throttle is the driver's input, so a forced value drives the
+	// rest of the model rather than just the transmitted frame
+	if forced, ok := sensorOverrides["ThrottlePosition"]; ok {
+		v.throttlePosition = int(forced)
+	}
+	v.app1, v.app2 = pedalVoltages(v.throttlePosition)
+
+	// However the throttle came to be closed, the engine idles
+	v.idle = v.throttlePosition == 0
+	if v.idle {
+		v.massAirFlow = float32(fluctuateFloat(2, 4)) // Mass Air Flow: 2 - 4 g/s
+	} else {
+		v.massAirFlow = float32(fluctuateFloat(8, 12)) // Mass Air Flow: 8 - 12 g/s
+	}
+
+	v.tickTipIn(dt)
+	v.oxygenSensor += int(math.Round(tipInEnrichment * v.tipIn))
+	v.tickRPM(dt)
+}
+
+// sweeping reports whether the gauge sweep after engine start is still
+// running.
+func (v vehicleModel) sweeping() bool {
+	return v.sweepLeft > 0
+}
+
+// tickSweep drives engine speed to the redline and the fuel level to full
+// and back, rising for the first half of gaugeSweepTime and falling for
+// the second. Speed then builds up to idle as after a normal start.
+func (v *vehicleModel) tickSweep(dt time.Duration) {
+	v.sweepLeft = max(0, v.sweepLeft-dt)
+	elapsed := 1 - float64(v.sweepLeft)/float64(gaugeSweepTime)
+	level := 1 - math.Abs(2*elapsed-1)
+	v.engineRPM = int(math.Round(level * float64(redlineRPM)))
+	v.fuelTankLevel = int(math.Round(level * 100))
+	v.throttlePosition = 0
+}
+
+// tickTipIn starts a tip-in transient when the throttle opens quickly and
+// decays it otherwise. The mixture briefly goes rich before the fuelling
+// catches up with the extra air.
+func (v *vehicleModel) tickTipIn(dt time.Duration) {
+	rate := float64(v.throttlePosition-v.lastThrottle) / dt.Seconds()
+	v.lastThrottle = v.throttlePosition
+	if rate >= tipInRate {
+		v.tipIn = 1
+		return
+	}
+	v.tipIn *= math.Exp(-dt.Seconds() / tipInDecay.Seconds())
+}
+
+// tickRPM moves the engine speed towards the target for the current
+// throttle and applies the rev limiter.
+func (v *vehicleModel) tickRPM(dt time.Duration) {
+	target := throttleTargetRPM(v.throttlePosition)
+	if v.idle {
+		target += v.idleHunt(dt)
+	}
+	target += int(math.Round(10 * tipInEnrichment * v.tipIn))
+	response := engine.response()
+	if v.limpHome {
+		target = min(target, limpHomeRPM)
+		response *= limpHomeResponse
+	}
+	v.engineRPM += int(float64(target-v.engineRPM) * math.Min(1, response*dt.Seconds()))
+	if v.idle {
+		v.engineRPM += fluctuate(-10, 10) // Engine RPM: idle hunt ± 10
+	} else {
+		v.engineRPM += fluctuate(-25, 25)
+	}
+	if v.limpHome {
+		v.engineRPM = min(v.engineRPM, limpHomeRPM)
+	}
+
+	// Cut fuel at the redline so the speed bounces off the limiter
+	v.fuelCut = v.engineRPM >= redlineRPM
+	if v.fuelCut {
+		v.engineRPM = redlineRPM - fluctuate(150, 300)
+		v.injectorTiming = 0
+	}
+}
+
+// idleHunt advances the idle hunt and returns its offset to the target
+// speed. The offset is scaled up by the engine's response at the hunt
+// frequency, so the speed itself swings by idleHuntAmplitude.
+func (v *vehicleModel) idleHunt(dt time.Duration) int {
+	if idleHuntAmplitude == 0 || idleHuntPeriod <= 0 {
+		return 0
+	}
+	v.huntTime = (v.huntTime + dt) % idleHuntPeriod
+	omega := 2 * math.Pi / idleHuntPeriod.Seconds()
+	k := engine.response()
+	gain := math.Sqrt(k*k+omega*omega) / k
+	return int(math.Round(idleHuntAmplitude * gain * math.Sin(omega*v.huntTime.Seconds())))
+}
+
+// throttleTargetRPM interpolates the steady-state engine speed for a
+// throttle position on throttleCurve.
+func throttleTargetRPM(throttle int) int {
+	if throttle <= throttleCurve[0].throttle {
+		return throttleCurve[0].rpm
+	}
+	for i := 1; i < len(throttleCurve); i++ {
+		lo, hi := throttleCurve[i-1], throttleCurve[i]
+		if throttle <= hi.throttle {
+			return lo.rpm + (hi.rpm-lo.rpm)*(throttle-lo.throttle)/(hi.throttle-lo.throttle)
+		}
+	}
+	return throttleCurve[len(throttleCurve)-1].rpm
+}
+
+// tickElectrical refreshes the readings that are available with the engine
+// off. The alternator lifts the battery voltage while the engine runs, and
+// a running engine has the ignition released from Crank to Run.
+func (v *vehicleModel) tickElectrical(running bool) {
+	// A cold battery rests lower, while the regulator charges it harder
+	// to compensate; both nominals are for 20 °C
+	cold := float64(20 - v.ambientTemp)
+	if running {
+		if v.ignition != ignitionRun {
+			v.ignition = ignitionRun
+		}
+		nominal := math.Min(math.Max(14.1+0.01*cold, 13.6), 14.8)
+		v.batteryVoltage = float32(fluctuateFloat(nominal-0.3, nominal+0.3)) // Battery Voltage (charging): 13.8 - 14.4 V at 20 °C
+	} else {
+		nominal := math.Min(math.Max(12.4-0.005*cold, 11.9), 12.7)
+		v.batteryVoltage = float32(fluctuateFloat(nominal-0.2, nominal+0.2)) // Battery Voltage (resting): 12.2 - 12.6 V at 20 °C
+	}
+}
+
+// setAmbient changes the ambient temperature, the shared environmental
+// input of the engine cool-down, battery and ambient sensor models.
+func setAmbient(temp int) {
+	simulationMux.Lock()
+	defer simulationMux.Unlock()
+	vehicle.ambientTemp = temp
+}
+
+// sensorValues returns every sensor reading in physical units, keyed by the
+// name of the message that transmits it, or of its signal for the pedal
+// sensors.
+func (v vehicleModel) sensorValues() map[string]float64 {
+	return map[string]float64{
+		"EngineTempSensor":     float64(v.engineTemp),
+		"InjectorTimingSensor": float64(v.injectorTiming),
+		"OxygenSensor":         float64(v.oxygenSensor),
+		"FuelTankLevel":        float64(v.fuelTankLevel),
+		"ThrottlePosition":     float64(v.throttlePosition),
+		"APP1":                 v.app1,
+		"APP2":                 v.app2,
+		"EngineRPM":            float64(v.engineRPM),
+		"MassAirFlow":          float64(v.massAirFlow),
+		"EngineAcceleration":   v.rpmRate,
+		"EngineHours":          v.engineHours.Hours(),
+		"AmbientTemp":          float64(v.ambientTemp),
+		"BatteryVoltage":       float64(v.batteryVoltage),
+		"KeyPosition":          float64(v.ignition),
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.einride.tech/can"
+	"go.einride.tech/can/pkg/socketcan"
+)
+
+// expectation is one line of a verify expectations file: the range a value
+// must stay in and the cadence its message must keep.
+type expectation struct {
+	Key     string // Message name, or Message.Signal, as in SignalChange
+	Message CANMessage
+	Min     float64
+	Max     float64
+	Cycle   time.Duration
+	Line    int
+}
+
+// loadExpectations reads an expectations file with one value per line:
+//
+//	EngineRPM 600 7000 100ms
+//	FrontLight.LowBeam 0 1
+//
+// The cycle is optional and defaults to the message interval. Blank lines
+// and lines starting with # are ignored.
+func loadExpectations(path string) ([]expectation, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var expectations []expectation
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		e, err := parseExpectation(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		e.Line = line
+		expectations = append(expectations, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(expectations) == 0 {
+		return nil, fmt.Errorf("%s: no expectations", path)
+	}
+	return expectations, nil
+}
+
+// parseExpectation parses a single expectations line.
+func parseExpectation(text string) (expectation, error) {
+	fields := strings.Fields(text)
+	if len(fields) != 3 && len(fields) != 4 {
+		return expectation{}, fmt.Errorf("expected \"<value> <min> <max> [cycle]\", got %q", text)
+	}
+	name, sigName, hasSignal := strings.Cut(fields[0], ".")
+	msg, ok := MessageByName(name)
+	if !ok {
+		return expectation{}, fmt.Errorf("unknown message %q", name)
+	}
+	if hasSignal {
+		if _, ok := msg.signal(sigName); !ok {
+			return expectation{}, fmt.Errorf("%s has no signal %q", name, sigName)
+		}
+	} else if msg.Value == nil {
+		return expectation{}, fmt.Errorf("%s has no single value, name one of its signals", name)
+	}
+
+	e := expectation{Key: fields[0], Message: msg, Cycle: msg.interval()}
+	var err error
+	if e.Min, err = strconv.ParseFloat(fields[1], 64); err != nil {
+		return expectation{}, fmt.Errorf("invalid minimum %q", fields[1])
+	}
+	if e.Max, err = strconv.ParseFloat(fields[2], 64); err != nil || e.Max < e.Min {
+		return expectation{}, fmt.Errorf("invalid maximum %q", fields[2])
+	}
+	if len(fields) == 4 {
+		if e.Cycle, err = time.ParseDuration(fields[3]); err != nil || e.Cycle <= 0 {
+			return expectation{}, fmt.Errorf("invalid cycle %q", fields[3])
+		}
+	}
+	return e, nil
+}
+
+// verifyResult accumulates what was received against one expectation.
+type verifyResult struct {
+	expectation
+	frames     uint64
+	invalid    uint64 // Short or failing their checksum
+	outOfRange uint64
+	offCadence uint64
+	low, high  float64
+	last       time.Time
+}
+
+// observe checks one frame of the expectation's message.
+func (r *verifyResult) observe(frame can.Frame, now time.Time, tolerance float64) {
+	msg := r.Message
+	data := frame.Data[:frame.Length]
+	if frame.Length < msg.DataLen || !msg.verifyChecksum(data) {
+		r.invalid++
+		return
+	}
+
+	if !r.last.IsZero() {
+		gap := now.Sub(r.last)
+		slack := time.Duration(float64(r.Cycle) * tolerance)
+		if gap < r.Cycle-slack || gap > r.Cycle+slack {
+			r.offCadence++
+		}
+	}
+	r.last = now
+
+	value := msg.physicalValues(data[:msg.DataLen])[r.Key]
+	if r.frames == 0 || value < r.low {
+		r.low = value
+	}
+	if r.frames == 0 || value > r.high {
+		r.high = value
+	}
+	r.frames++
+	if value < r.Min || value > r.Max {
+		r.outOfRange++
+	}
+}
+
+// failures describes why the expectation failed, nil if it passed.
+func (r *verifyResult) failures() []string {
+	if r.frames == 0 {
+		return []string{"missing"}
+	}
+	var failed []string
+	if r.outOfRange > 0 {
+		failed = append(failed, fmt.Sprintf("%d of %d values out of range", r.outOfRange, r.frames))
+	}
+	if r.offCadence > 0 {
+		failed = append(failed, fmt.Sprintf("%d gaps off the %s cycle", r.offCadence, r.Cycle))
+	}
+	if r.invalid > 0 {
+		failed = append(failed, fmt.Sprintf("%d invalid frames", r.invalid))
+	}
+	return failed
+}
+
+// runVerify implements "vecu verify": listen on a bus for a while and check
+// the received values and cadences against an expectations file, failing
+// if any message is missing, out of range or off-cadence.
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	iface := fs.String("iface", "vcan0", "CAN interface to listen on")
+	expectPath := fs.String("expect", "", "expectations file (required)")
+	duration := fs.Duration("duration", 10*time.Second, "how long to listen")
+	tolerance := fs.Float64("tolerance", 0.2, "allowed deviation from the cycle time, as a fraction")
+	dbcPath := fs.String("dbc", "", "also load message definitions from this DBC file")
+	aliasSpec := fs.String("alias", "", "rename imported DBC messages and signals as Old->New,...")
+	fs.Parse(args)
+
+	if *expectPath == "" {
+		return fmt.Errorf("verify: -expect is required")
+	}
+	var aliases map[string]string
+	if *aliasSpec != "" {
+		var err error
+		if aliases, err = parseAliases(*aliasSpec); err != nil {
+			return err
+		}
+	}
+	if err := loadMessages(*dbcPath, aliases); err != nil {
+		return err
+	}
+	expectations, err := loadExpectations(*expectPath)
+	if err != nil {
+		return err
+	}
+	results := make(map[uint32][]*verifyResult)
+	ordered := make([]*verifyResult, len(expectations))
+	for i, e := range expectations {
+		ordered[i] = &verifyResult{expectation: e}
+		results[e.Message.ID] = append(results[e.Message.ID], ordered[i])
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+	conn, err := socketcan.DialContext(ctx, "can", *iface)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", *iface, err)
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	log.Printf("Verifying %d expectations on %s for %s. . .", len(expectations), *iface, *duration)
+	recv := socketcan.NewReceiver(conn)
+	for recv.Receive() {
+		if recv.HasErrorFrame() {
+			continue
+		}
+		frame := recv.Frame()
+		now := time.Now()
+		for _, r := range results[frame.ID] {
+			r.observe(frame, now, *tolerance)
+		}
+	}
+	// Closing the connection at the end of the run also ends the loop with
+	// an error, which is not a failure
+	if err := recv.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to receive on %s: %w", *iface, err)
+	}
+
+	failed := 0
+	for _, r := range ordered {
+		if problems := r.failures(); problems != nil {
+			failed++
+			log.Printf("FAIL %s (line %d): %s", r.Key, r.Line, strings.Join(problems, ", "))
+			continue
+		}
+		log.Printf("PASS %s: %d frames, values %g to %g", r.Key, r.frames, r.low, r.high)
+	}
+	if failed > 0 {
+		return fmt.Errorf("verification failed: %d of %d expectations not met", failed, len(ordered))
+	}
+	log.Printf("Verification passed: all %d expectations met", len(ordered))
+	return nil
+}
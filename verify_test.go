@@ -0,0 +1,95 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.einride.tech/can"
+)
+
+func TestParseExpectation(t *testing.T) {
+	if err := loadMessages("", nil); err != nil {
+		t.Fatal(err)
+	}
+	rpm, _ := MessageByName("EngineRPM")
+	lights, _ := MessageByName("FrontLight")
+	tests := []struct {
+		text    string
+		want    expectation
+		wantErr string
+	}{
+		{text: "EngineRPM 600 7000 100ms", want: expectation{Key: "EngineRPM", Min: 600, Max: 7000, Cycle: 100 * time.Millisecond}},
+		{text: "FrontLight.LowBeam 0 1", want: expectation{Key: "FrontLight.LowBeam", Min: 0, Max: 1, Cycle: lights.interval()}},
+		{text: "EngineRPM -5.5 -5.5", want: expectation{Key: "EngineRPM", Min: -5.5, Max: -5.5, Cycle: rpm.interval()}},
+		{text: "EngineRPM 600", wantErr: "expected"},
+		{text: "Gearbox 0 1", wantErr: `unknown message "Gearbox"`},
+		{text: "FrontLight.Hazard 0 1", wantErr: `FrontLight has no signal "Hazard"`},
+		{text: "FrontLight 0 1", wantErr: "no single value"},
+		{text: "EngineRPM low 7000", wantErr: `invalid minimum "low"`},
+		{text: "EngineRPM 7000 600", wantErr: `invalid maximum "600"`},
+		{text: "EngineRPM 600 7000 0s", wantErr: `invalid cycle "0s"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := parseExpectation(tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseExpectation() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Key != tt.want.Key || got.Min != tt.want.Min || got.Max != tt.want.Max || got.Cycle != tt.want.Cycle {
+				t.Errorf("parseExpectation() = %s %g %g %s, want %s %g %g %s", got.Key, got.Min, got.Max, got.Cycle, tt.want.Key, tt.want.Min, tt.want.Max, tt.want.Cycle)
+			}
+			if name, _, _ := strings.Cut(tt.want.Key, "."); got.Message.Name != name {
+				t.Errorf("parseExpectation() message = %s, want %s", got.Message.Name, name)
+			}
+		})
+	}
+}
+
+func TestVerifyResultObserve(t *testing.T) {
+	if err := loadMessages("", nil); err != nil {
+		t.Fatal(err)
+	}
+	e, err := parseExpectation("EngineRPM 600 7000 100ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &verifyResult{expectation: e}
+	rpmFrame := func(rpm uint16) can.Frame {
+		var data [8]byte
+		data[0], data[1] = byte(rpm>>8), byte(rpm)
+		e.Message.applyChecksum(&data)
+		return can.Frame{ID: e.Message.ID, Length: 8, Data: data}
+	}
+	corrupt := rpmFrame(900)
+	corrupt.Data[7] ^= 0xFF
+
+	start := time.Now()
+	r.observe(rpmFrame(800), start, 0.2)
+	r.observe(rpmFrame(850), start.Add(110*time.Millisecond), 0.2)
+	r.observe(rpmFrame(8000), start.Add(210*time.Millisecond), 0.2)
+	r.observe(rpmFrame(900), start.Add(400*time.Millisecond), 0.2) // Off cadence
+	r.observe(corrupt, start.Add(500*time.Millisecond), 0.2)
+	r.observe(can.Frame{ID: e.Message.ID, Length: 2}, start.Add(600*time.Millisecond), 0.2)
+
+	if r.frames != 4 || r.invalid != 2 || r.outOfRange != 1 || r.offCadence != 1 {
+		t.Errorf("frames %d, invalid %d, out of range %d, off cadence %d, want 4, 2, 1, 1", r.frames, r.invalid, r.outOfRange, r.offCadence)
+	}
+	if r.low != 800 || r.high != 8000 {
+		t.Errorf("values %g to %g, want 800 to 8000", r.low, r.high)
+	}
+	want := []string{"1 of 4 values out of range", "1 gaps off the 100ms cycle", "2 invalid frames"}
+	if got := r.failures(); !slices.Equal(got, want) {
+		t.Errorf("failures() = %q, want %q", got, want)
+	}
+	if got := (&verifyResult{expectation: e}).failures(); !slices.Equal(got, []string{"missing"}) {
+		t.Errorf("failures() with no frames = %q, want [missing]", got)
+	}
+}